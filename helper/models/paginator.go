@@ -5,6 +5,7 @@ import (
 	"math"
 
 	"github.com/BlackMocca/sqlx"
+	"github.com/spf13/cast"
 )
 
 const (
@@ -60,7 +61,11 @@ func (p *Paginator) SetTotalFromRows(rows *sqlx.Rows) error {
 		if len(columns) > 0 && len(values) > 0 {
 			for index, column := range columns {
 				if column == PSQL_TOTAL_ROW_KEY {
-					total := int(values[index].(int64))
+					// driver แต่ละตัวคืนชนิดต่างกัน เช่น int64, int32 หรือ []byte
+					total := cast.ToInt(values[index])
+					if bytes, ok := values[index].([]byte); ok {
+						total = cast.ToInt(string(bytes))
+					}
 					p.setTotalEntrySizes(total)
 					p.setTotalPages()
 				}
@@ -0,0 +1,47 @@
+package minio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+
+	minioLib "github.com/minio/minio-go/v7"
+)
+
+// BlobStore เก็บ blob ไว้ใน bucket ใช้เป็น claim-check store ของ redis.TaskQueue ได้
+type BlobStore struct {
+	client *Client
+	bucket string
+	prefix string
+}
+
+func NewBlobStore(client *Client, bucketName string, prefix string) *BlobStore {
+	return &BlobStore{
+		client: client,
+		bucket: bucketName,
+		prefix: prefix,
+	}
+}
+
+func (b *BlobStore) objectName(key string) string {
+	return path.Join(b.prefix, key)
+}
+
+func (b *BlobStore) PutBlob(ctx context.Context, key string, data []byte) error {
+	return b.client.UploadFileWithReader(ctx, b.bucket, b.objectName(key), bytes.NewReader(data), int64(len(data)), "application/octet-stream", "")
+}
+
+func (b *BlobStore) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	object, err := b.client.GetClient().GetObject(ctx, b.bucket, b.objectName(key), minioLib.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return io.ReadAll(object)
+}
+
+func (b *BlobStore) DeleteBlob(ctx context.Context, key string) error {
+	return b.client.RemoveObject(ctx, b.bucket, b.objectName(key))
+}
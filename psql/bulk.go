@@ -0,0 +1,413 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultBulkChunkSize = 1000
+	// maxBulkParams คือจำนวน placeholder สูงสุดต่อคำสั่งของ Postgres และ MySQL
+	maxBulkParams = 65535
+	bulkTempTable = "bulk_upsert_tmp"
+)
+
+type bulkOptions struct {
+	chunkSize              int
+	onProgress             func(written, total int)
+	continueOnError        bool
+	clickhouseInsertUpsert bool
+}
+
+// BulkOption ปรับการทำงานของ BulkInsert และ BulkUpsert
+type BulkOption func(*bulkOptions)
+
+// WithChunkSize กำหนดจำนวนแถวต่อ chunk ค่าเริ่มต้นคือ DefaultBulkChunkSize
+// สำหรับ multi-row VALUES จะถูกลดลงอัตโนมัติถ้าจำนวน placeholder เกินที่ฐานข้อมูลรับได้
+func WithChunkSize(n int) BulkOption {
+	return func(o *bulkOptions) {
+		o.chunkSize = n
+	}
+}
+
+// WithProgress เรียก fn หลังเขียนแต่ละ chunk สำเร็จ พร้อมจำนวนแถวที่เขียนแล้วและจำนวนทั้งหมด
+func WithProgress(fn func(written, total int)) BulkOption {
+	return func(o *bulkOptions) {
+		o.onProgress = fn
+	}
+}
+
+// WithContinueOnError เขียน chunk ถัดไปต่อแม้ chunk ก่อนหน้าล้มเหลว แล้วรวม error ทั้งหมดใน BulkError
+// ถ้าทำงานภายใน WithTx ของ Postgres transaction จะใช้ไม่ได้หลัง chunk แรกที่ล้มเหลว
+func WithContinueOnError() BulkOption {
+	return func(o *bulkOptions) {
+		o.continueOnError = true
+	}
+}
+
+// WithClickhouseInsertAsUpsert ยอมให้ BulkUpsert บน ClickHouse เขียนแบบ insert ธรรมดา
+// ClickHouse ไม่มี unique constraint แถวที่ key ซ้ำจะถูกรวมภายหลังก็ต่อเมื่อตารางเป็น ReplacingMergeTree
+// (หรือ engine ที่รวมแถวเอง) และ conflictKeys กับ updateColumns จะไม่ถูกใช้
+func WithClickhouseInsertAsUpsert() BulkOption {
+	return func(o *bulkOptions) {
+		o.clickhouseInsertUpsert = true
+	}
+}
+
+// ChunkError คือ error ของ chunk เดียว Offset คือลำดับแถวแรกของ chunk ใน rows
+type ChunkError struct {
+	Chunk  int
+	Offset int
+	Rows   int
+	Err    error
+}
+
+func (e ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d (rows %d-%d): %v", e.Chunk, e.Offset, e.Offset+e.Rows-1, e.Err)
+}
+
+func (e ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// BulkError รวม error ของทุก chunk ที่เขียนไม่สำเร็จ
+type BulkError struct {
+	Chunks []ChunkError
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("failed to write %d chunk(s), first error: %v", len(e.Chunks), e.Chunks[0])
+}
+
+func (e *BulkError) Unwrap() []error {
+	errs := make([]error, len(e.Chunks))
+	for i, chunk := range e.Chunks {
+		errs[i] = chunk
+	}
+	return errs
+}
+
+type bulkRequest struct {
+	table         string
+	columns       []string
+	conflictKeys  []string
+	updateColumns []string
+	upsert        bool
+}
+
+type bulkWriter func(ctx context.Context, rows [][]interface{}) error
+
+// BulkInsert เขียน rows ลง table แบบแบ่ง chunk ด้วยวิธีที่เร็วที่สุดของแต่ละ driver
+// Postgres ใช้ COPY (ถ้าอยู่ใน WithTx จะใช้ multi-row VALUES แทนเพื่อให้อยู่ใน transaction เดียวกัน)
+// MySQL ใช้ multi-row VALUES, MSSQL ใช้ bulk copy และ ClickHouse ใช้ native batch
+// native batch ของ ClickHouse ใช้ connection แยกเสมอ จึงไม่อยู่ใน transaction ของ WithTx ที่ครอบอยู่
+// COPY ของ Postgres เขียนผ่าน pgxpool โดยตรงจึงไม่ผ่าน TracingHook, OTelHook และ QueryStatsHook
+// ถ้าต้องการให้ถูก trace ให้เรียกภายใน WithTx ซึ่งจะใช้ multi-row VALUES ผ่าน hook แทน
+// คืนจำนวนแถวที่เขียนสำเร็จ และ *BulkError ถ้ามี chunk ที่ล้มเหลว
+// ถ้า ctx ถูกยกเลิกระหว่างทาง จะคืน ctx.Err() รวมกับ *BulkError ของ chunk ที่ล้มเหลวก่อนหน้า
+func (c *Client) BulkInsert(ctx context.Context, table string, columns []string, rows [][]interface{}, opts ...BulkOption) (int, error) {
+	return c.bulk(ctx, bulkRequest{table: table, columns: columns}, rows, opts)
+}
+
+// BulkUpsert เหมือน BulkInsert แต่แถวที่ conflictKeys ซ้ำจะแก้ updateColumns แทน
+// ส่ง updateColumns ว่างเพื่อข้ามแถวที่ซ้ำ แถวใน chunk เดียวกันต้องมี key ไม่ซ้ำกัน
+// ClickHouse ไม่มี constraint จึงคืน error เว้นแต่ส่ง WithClickhouseInsertAsUpsert
+func (c *Client) BulkUpsert(ctx context.Context, table string, columns []string, rows [][]interface{}, conflictKeys []string, updateColumns []string, opts ...BulkOption) (int, error) {
+	if len(conflictKeys) == 0 {
+		return 0, fmt.Errorf("conflict keys are required")
+	}
+	if Driver(c.driverName) == Clickhouse {
+		options := &bulkOptions{}
+		for _, opt := range opts {
+			opt(options)
+		}
+		if !options.clickhouseInsertUpsert {
+			return 0, fmt.Errorf("clickhouse does not support upsert, use WithClickhouseInsertAsUpsert to insert into a ReplacingMergeTree table")
+		}
+	}
+	return c.bulk(ctx, bulkRequest{
+		table:         table,
+		columns:       columns,
+		conflictKeys:  conflictKeys,
+		updateColumns: updateColumns,
+		upsert:        true,
+	}, rows, opts)
+}
+
+func (c *Client) bulk(ctx context.Context, req bulkRequest, rows [][]interface{}, opts []BulkOption) (int, error) {
+	options := &bulkOptions{chunkSize: DefaultBulkChunkSize}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.chunkSize <= 0 {
+		options.chunkSize = DefaultBulkChunkSize
+	}
+
+	if len(req.columns) == 0 {
+		return 0, fmt.Errorf("columns are required")
+	}
+	for i, row := range rows {
+		if len(row) != len(req.columns) {
+			return 0, fmt.Errorf("row %d has %d values, expected %d", i, len(row), len(req.columns))
+		}
+	}
+
+	write, maxRows := c.bulkWriter(ctx, req)
+	chunkSize := options.chunkSize
+	if maxRows > 0 && chunkSize > maxRows {
+		chunkSize = maxRows
+	}
+
+	written := 0
+	bulkErr := &BulkError{}
+	for chunk, offset := 0, 0; offset < len(rows); chunk, offset = chunk+1, offset+chunkSize {
+		if err := ctx.Err(); err != nil {
+			if len(bulkErr.Chunks) > 0 {
+				return written, errors.Join(err, bulkErr)
+			}
+			return written, err
+		}
+
+		end := offset + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := write(ctx, rows[offset:end]); err != nil {
+			bulkErr.Chunks = append(bulkErr.Chunks, ChunkError{Chunk: chunk, Offset: offset, Rows: end - offset, Err: err})
+			if !options.continueOnError {
+				break
+			}
+			continue
+		}
+
+		written += end - offset
+		if options.onProgress != nil {
+			options.onProgress(written, len(rows))
+		}
+	}
+
+	if len(bulkErr.Chunks) > 0 {
+		return written, bulkErr
+	}
+	return written, nil
+}
+
+// bulkWriter เลือกวิธีเขียนตาม driver คืน writer และจำนวนแถวสูงสุดต่อ chunk (0 คือไม่จำกัด)
+func (c *Client) bulkWriter(ctx context.Context, req bulkRequest) (bulkWriter, int) {
+	valuesWriter := func(ctx context.Context, rows [][]interface{}) error {
+		return c.insertValues(ctx, req, rows)
+	}
+	maxValuesRows := maxBulkParams / len(req.columns)
+
+	switch Driver(c.driverName) {
+	case Postgres:
+		// COPY ใช้ connection ของ pool โดยตรงจึงร่วม transaction ของ WithTx ไม่ได้
+		if _, inTx := c.TxFromContext(ctx); inTx || c.pool == nil {
+			return valuesWriter, maxValuesRows
+		}
+		if req.upsert {
+			return func(ctx context.Context, rows [][]interface{}) error {
+				return c.copyUpsertPostgres(ctx, req, rows)
+			}, 0
+		}
+		return func(ctx context.Context, rows [][]interface{}) error {
+			_, err := c.pool.CopyFrom(ctx, pgx.Identifier(strings.Split(req.table, ".")), req.columns, pgx.CopyFromRows(rows))
+			return err
+		}, 0
+	case Mssql:
+		return func(ctx context.Context, rows [][]interface{}) error {
+			return c.bulkCopyMssql(ctx, req, rows)
+		}, 0
+	case Clickhouse:
+		return func(ctx context.Context, rows [][]interface{}) error {
+			return c.batchClickhouse(ctx, req, rows)
+		}, 0
+	default:
+		return valuesWriter, maxValuesRows
+	}
+}
+
+func (c *Client) quoteColumns(columns []string, prefix string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = prefix + quoteIdentifier(Driver(c.driverName), column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// insertValues เขียน rows ด้วย INSERT ... VALUES (...), (...) ผ่าน Executor(ctx)
+func (c *Client) insertValues(ctx context.Context, req bulkRequest, rows [][]interface{}) error {
+	databaseType := Driver(c.driverName)
+	tuple := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(req.columns)), ", ") + ")"
+	tuples := make([]string, len(rows))
+	args := make([]interface{}, 0, len(rows)*len(req.columns))
+	for i, row := range rows {
+		tuples[i] = tuple
+		args = append(args, row...)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quoteIdentifier(databaseType, req.table), c.quoteColumns(req.columns, ""), strings.Join(tuples, ", "))
+	if req.upsert {
+		sets := make([]string, len(req.updateColumns))
+		for i, column := range req.updateColumns {
+			quoted := quoteIdentifier(databaseType, column)
+			if databaseType == MySQL {
+				sets[i] = fmt.Sprintf("%s = VALUES(%s)", quoted, quoted)
+			} else {
+				sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", quoted, quoted)
+			}
+		}
+
+		switch {
+		case databaseType == MySQL && len(sets) == 0:
+			// MySQL ไม่มี DO NOTHING จึงตั้ง key ให้เท่าค่าเดิม
+			key := quoteIdentifier(databaseType, req.conflictKeys[0])
+			query += fmt.Sprintf(" ON DUPLICATE KEY UPDATE %s = %s", key, key)
+		case databaseType == MySQL:
+			query += " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+		case len(sets) == 0:
+			query += fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", c.quoteColumns(req.conflictKeys, ""))
+		default:
+			query += fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", c.quoteColumns(req.conflictKeys, ""), strings.Join(sets, ", "))
+		}
+	}
+
+	db := c.Executor(ctx)
+	_, err := db.ExecContext(ctx, db.Rebind(query), args...)
+	return err
+}
+
+// copyUpsertPostgres COPY ลง temp table แล้ว INSERT ... ON CONFLICT เข้าตารางจริงใน transaction เดียว
+func (c *Client) copyUpsertPostgres(ctx context.Context, req bulkRequest, rows [][]interface{}) error {
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %v", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// สร้าง temp table เฉพาะคอลัมน์ที่เขียน เพื่อไม่ให้ติด NOT NULL หรือ default ของคอลัมน์อื่นในตารางจริง
+	table := quoteIdentifier(Postgres, req.table)
+	columns := c.quoteColumns(req.columns, "")
+	if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA", bulkTempTable, columns, table)); err != nil {
+		return fmt.Errorf("failed to create temp table: %v", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{bulkTempTable}, req.columns, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT (%s)", table, columns, columns, bulkTempTable, c.quoteColumns(req.conflictKeys, ""))
+	if len(req.updateColumns) == 0 {
+		query += " DO NOTHING"
+	} else {
+		sets := make([]string, len(req.updateColumns))
+		for i, column := range req.updateColumns {
+			quoted := quoteIdentifier(Postgres, column)
+			sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", quoted, quoted)
+		}
+		query += " DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// bulkCopyMssql เขียนด้วย bulk copy ของ go-mssqldb ถ้าเป็น upsert จะ copy ลง temp table แล้ว MERGE
+func (c *Client) bulkCopyMssql(ctx context.Context, req bulkRequest, rows [][]interface{}) error {
+	return c.WithTx(ctx, &TxOptions{}, func(ctx context.Context, tx *sqlx.Tx) error {
+		target := req.table
+		tempTable := "#" + bulkTempTable
+		if req.upsert {
+			query := fmt.Sprintf("SELECT TOP 0 %s INTO %s FROM %s", c.quoteColumns(req.columns, ""), tempTable, quoteIdentifier(Mssql, req.table))
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("failed to create temp table: %v", err)
+			}
+			target = tempTable
+		}
+
+		stmt, err := tx.PrepareContext(ctx, mssql.CopyIn(target, mssql.BulkOptions{}, req.columns...))
+		if err != nil {
+			return fmt.Errorf("failed to prepare bulk copy: %v", err)
+		}
+		defer stmt.Close()
+		for _, row := range rows {
+			if _, err := stmt.ExecContext(ctx, row...); err != nil {
+				return err
+			}
+		}
+		// Exec ที่ไม่มี argument คือการส่งแถวที่สะสมไว้ทั้งหมด
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			return err
+		}
+		if !req.upsert {
+			return nil
+		}
+
+		on := make([]string, len(req.conflictKeys))
+		for i, key := range req.conflictKeys {
+			quoted := quoteIdentifier(Mssql, key)
+			on[i] = fmt.Sprintf("target.%s = source.%s", quoted, quoted)
+		}
+		query := fmt.Sprintf("MERGE INTO %s AS target USING %s AS source ON %s", quoteIdentifier(Mssql, req.table), tempTable, strings.Join(on, " AND "))
+		if len(req.updateColumns) > 0 {
+			sets := make([]string, len(req.updateColumns))
+			for i, column := range req.updateColumns {
+				quoted := quoteIdentifier(Mssql, column)
+				sets[i] = fmt.Sprintf("target.%s = source.%s", quoted, quoted)
+			}
+			query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", ")
+		}
+		query += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);", c.quoteColumns(req.columns, ""), c.quoteColumns(req.columns, "source."))
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DROP TABLE "+tempTable); err != nil {
+			return fmt.Errorf("failed to drop temp table: %v", err)
+		}
+		return nil
+	})
+}
+
+// batchClickhouse ส่ง rows เป็น native block เดียวผ่าน prepared INSERT ภายใน transaction ของ clickhouse-go
+// clickhouse-go รับได้เพียง batch เดียวต่อ transaction จึงเปิด connection แยกของตัวเองทุก chunk
+// แม้จะถูกเรียกภายใน WithTx ก็ตาม (ClickHouse ไม่มี transaction จริง การ rollback ของผู้เรียกจึงไม่ลบแถวที่เขียนแล้วอยู่แล้ว)
+func (c *Client) batchClickhouse(ctx context.Context, req bulkRequest, rows [][]interface{}) error {
+	conn, err := c.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %v", err)
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin batch: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("INSERT INTO %s (%s)", quoteIdentifier(Clickhouse, req.table), c.quoteColumns(req.columns, ""))
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -3,6 +3,9 @@ package psql
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"log"
+	"time"
 
 	_ "github.com/ClickHouse/clickhouse-go/v2"
 	_ "github.com/denisenkom/go-mssqldb"
@@ -11,9 +14,9 @@ import (
 	"github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
-	pg "github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
 	"github.com/qustavo/sqlhooks/v2"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Client struct {
@@ -21,55 +24,161 @@ type Client struct {
 	connectionURI string
 	driverName    string
 	tracer        opentracing.Tracer
+	replicas      *replicaSet
+	// pool ของ pgx ใช้กับงานที่ database/sql ทำไม่ได้ เช่น COPY มีค่าเฉพาะ Postgres
+	pool *pgxpool.Pool
 }
 
-func connectPostgres(ctx context.Context, connectionStr string, databaseType Driver, tracing opentracing.Tracer) (client *Client, err error) {
-	pool, err := pgxpool.New(ctx, connectionStr)
+func connectPostgres(ctx context.Context, connectionStr string, options *clientOptions, hooks []sqlhooks.Hooks) (*sqlx.DB, *pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(connectionStr)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if options.poolMinConns > 0 {
+		config.MinConns = options.poolMinConns
+	}
+	if options.poolMaxConns > 0 {
+		config.MaxConns = options.poolMaxConns
+	}
+	if options.healthCheckPeriod > 0 {
+		config.HealthCheckPeriod = options.healthCheckPeriod
+	}
+	if options.connMaxLifetime > 0 {
+		config.MaxConnLifetime = options.connMaxLifetime
+	}
+	if options.connMaxIdleTime > 0 {
+		config.MaxConnIdleTime = options.connMaxIdleTime
+	}
+	if options.statementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = fmt.Sprint(options.statementTimeout.Milliseconds())
+	}
+	if options.applicationName != "" {
+		config.ConnConfig.RuntimeParams["application_name"] = options.applicationName
 	}
-	var db *sqlx.DB
-	var driverName Driver
 
-	if tracing != nil {
-		switch driverName {
-		case Postgres:
-			sql.Register(opentracing_postgres, sqlhooks.Wrap(&pg.Driver{}, NewTracingHook(tracing)))
-		}
-	} else {
-		driverName = databaseType
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	db = sqlx.NewDb(stdlib.OpenDBFromPool(pool), string(driverName))
+	// ครอบ connector ของ pool แทนการ register driver ใหม่ เพื่อให้ query ผ่าน pool ถูก trace ด้วย
+	sqlDB := sql.OpenDB(wrapConnector(stdlib.GetPoolConnector(pool), hooks))
+	// connection ที่ idle ถูกจัดการโดย pgxpool
+	sqlDB.SetMaxIdleConns(0)
+
+	db := sqlx.NewDb(sqlDB, string(Postgres))
 	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		pool.Close()
+		return nil, nil, err
+	}
+
+	maxOpenConns := options.maxOpenConns
+	if maxOpenConns <= 0 {
+		maxOpenConns = DefaultPostgresMaxOpenConns
+		if options.poolMaxConns > 0 {
+			maxOpenConns = int(options.poolMaxConns)
+		}
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+
+	return db, pool, nil
+}
+
+// buildHooks รวม tracing hook ตาม option กับ hook ของผู้ใช้ ใช้ทั้งกับ primary และ replica
+func buildHooks(options *clientOptions, databaseType Driver) []sqlhooks.Hooks {
+	hooks := []sqlhooks.Hooks{}
+	if options.tracer != nil {
+		hooks = append(hooks, newTracingHook(options.tracer, databaseType, options.tracingOpts...))
+	}
+	if options.otelTracer != nil {
+		hooks = append(hooks, NewOTelHook(options.otelTracer, databaseType))
+	}
+	return append(hooks, options.hooks...)
+}
+
+func connectWithHooks(ctx context.Context, connectionStr string, databaseType Driver, hooks []sqlhooks.Hooks) (*sqlx.DB, error) {
+	connector, err := newDriverConnector(string(databaseType), connectionStr)
+	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(4)
 
-	return &Client{
-		db:            db,
-		connectionURI: connectionStr,
-		driverName:    string(driverName),
-		tracer:        tracing,
-	}, nil
+	db := sqlx.NewDb(sql.OpenDB(wrapConnector(connector, hooks)), string(databaseType))
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
-func connect(ctx context.Context, connectionStr string, databaseType Driver, tracing opentracing.Tracer) (client *Client, err error) {
+// connectOnce เชื่อมต่อหนึ่งครั้ง คืน pgxpool.Pool ด้วยเมื่อเป็น Postgres
+func connectOnce(ctx context.Context, connectionStr string, databaseType Driver, options *clientOptions, hooks []sqlhooks.Hooks) (*sqlx.DB, *pgxpool.Pool, error) {
 	if databaseType == Postgres {
-		return connectPostgres(ctx, connectionStr, databaseType, tracing)
+		return connectPostgres(ctx, connectionStr, options, hooks)
 	}
 
-	var driver Driver
-	switch databaseType {
-	case Mssql:
-		driver = Mssql
-	case Clickhouse:
-		driver = Clickhouse
-	case MySQL:
-		driver = MySQL
+	dsn, err := applyDSNOptions(databaseType, connectionStr, options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to apply connection options: %v", err)
 	}
 
-	db, err := sqlx.Connect(string(driver), connectionStr)
+	var db *sqlx.DB
+	if len(hooks) == 0 {
+		db, err = sqlx.ConnectContext(ctx, string(databaseType), dsn)
+	} else {
+		db, err = connectWithHooks(ctx, dsn, databaseType, hooks)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if options.maxOpenConns > 0 {
+		db.SetMaxOpenConns(options.maxOpenConns)
+	}
+	if options.maxIdleConns > 0 {
+		db.SetMaxIdleConns(options.maxIdleConns)
+	}
+	if options.connMaxLifetime > 0 {
+		db.SetConnMaxLifetime(options.connMaxLifetime)
+	}
+	if options.connMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(options.connMaxIdleTime)
+	}
+	return db, nil, nil
+}
+
+func connect(ctx context.Context, connectionStr string, databaseType Driver, opts ...ClientOption) (client *Client, err error) {
+	options := &clientOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.err != nil {
+		return nil, options.err
+	}
+
+	hooks := buildHooks(options, databaseType)
+
+	backoff := options.connectBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	var db *sqlx.DB
+	var pool *pgxpool.Pool
+	for attempt := 0; ; attempt++ {
+		db, pool, err = connectOnce(ctx, connectionStr, databaseType, options, hooks)
+		if err == nil || attempt >= options.connectRetries {
+			break
+		}
+
+		log.Printf("Failed to connect to %s, retrying in %v (%d/%d): %v", databaseType, backoff, attempt+1, options.connectRetries, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -77,17 +186,23 @@ func connect(ctx context.Context, connectionStr string, databaseType Driver, tra
 	return &Client{
 		db:            db,
 		connectionURI: connectionStr,
-		driverName:    string(driver),
-		tracer:        tracing,
+		driverName:    string(databaseType),
+		tracer:        options.tracer,
+		pool:          pool,
 	}, nil
 }
 
-func NewConnection(connectionStr string, databaseType Driver) (*Client, error) {
-	return connect(context.Background(), connectionStr, databaseType, nil)
+func NewConnection(connectionStr string, databaseType Driver, opts ...ClientOption) (*Client, error) {
+	return connect(context.Background(), connectionStr, databaseType, opts...)
+}
+
+func NewConnectionWithTracing(connectionStr string, databaseType Driver, tracing opentracing.Tracer, opts ...ClientOption) (client *Client, err error) {
+	return connect(context.Background(), connectionStr, databaseType, append([]ClientOption{WithTracer(tracing)}, opts...)...)
 }
 
-func NewConnectionWithTracing(connectionStr string, databaseType Driver, tracing opentracing.Tracer) (client *Client, err error) {
-	return connect(context.Background(), connectionStr, databaseType, tracing)
+// NewConnectionWithOTel เหมือน NewConnectionWithTracing แต่สร้าง span ด้วย OpenTelemetry tracer
+func NewConnectionWithOTel(connectionStr string, databaseType Driver, tracer trace.Tracer, opts ...ClientOption) (client *Client, err error) {
+	return connect(context.Background(), connectionStr, databaseType, append([]ClientOption{WithOTelTracer(tracer)}, opts...)...)
 }
 
 func (c *Client) GetClient() *sqlx.DB {
@@ -108,6 +223,19 @@ func (c *Client) IsConnect() bool {
 	}
 	return false
 }
+
+// Close ปิด connection ของ client รวมถึง pgxpool.Pool ที่อยู่เบื้องหลัง และ replica ทั้งหมด
 func (c *Client) Close() error {
+	if c.replicas != nil {
+		if err := c.replicas.close(); err != nil {
+			log.Printf("Warning: failed to close replicas: %v", err)
+		}
+	}
+	// sql.DB ที่สร้างจาก pool ไม่ปิด pool ให้ จึงต้องปิดเอง
+	defer func() {
+		if c.pool != nil {
+			c.pool.Close()
+		}
+	}()
 	return c.db.Close()
 }
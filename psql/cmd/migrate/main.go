@@ -0,0 +1,122 @@
+// migrate รัน SQL migration จาก directory ด้วย psql.Migrator
+//
+//	migrate -driver pgx -dsn "postgres://..." -dir ./migrations up
+//	migrate down [steps]
+//	migrate status
+//	migrate redo
+//	migrate create add_users_table
+//
+// ถ้าไม่ระบุ -driver, -dsn หรือ -dir จะอ่านจาก DB_DRIVER, DB_DSN และ MIGRATION_DIR
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/GodeFvt/go-backend/helper"
+	"github.com/GodeFvt/go-backend/psql"
+)
+
+func main() {
+	driver := flag.String("driver", helper.GetENV("DB_DRIVER", string(psql.Postgres)), "database driver (pgx, mysql, sqlserver, clickhouse)")
+	dsn := flag.String("dsn", helper.GetENV("DB_DSN", ""), "database connection string")
+	dir := flag.String("dir", helper.GetENV("MIGRATION_DIR", "migrations"), "migration directory")
+	table := flag.String("table", psql.DefaultMigrationTable, "migration table")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] up|down [steps]|status|redo|create <name>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			log.Fatal("create requires a migration name")
+		}
+		if err := create(*dir, args[1]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *dsn == "" {
+		log.Fatal("dsn is required")
+	}
+	client, err := psql.NewConnection(*dsn, psql.Driver(*driver))
+	if err != nil {
+		log.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	migrator := psql.NewMigrator(client, os.DirFS(*dir), psql.WithMigrationTable(*table))
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				log.Fatalf("invalid steps: %v", err)
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case "redo":
+		err = migrator.Redo(ctx)
+	case "status":
+		err = status(ctx, migrator)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func status(ctx context.Context, migrator *psql.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.ChecksumMismatch {
+			state += " (checksum mismatch)"
+		}
+		fmt.Printf("%d_%s\t%s\n", s.Version, s.Name, state)
+	}
+	return nil
+}
+
+func create(dir string, name string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		if err := os.WriteFile(path, []byte{}, 0o644); err != nil {
+			return err
+		}
+		fmt.Println("Created", path)
+	}
+	return nil
+}
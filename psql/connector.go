@@ -0,0 +1,282 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// connectorDriver ทำให้ driver.Connector ใช้กับ sqlhooks.Wrap ได้ ซึ่งรับเฉพาะ driver.Driver
+type connectorDriver struct {
+	ctx       context.Context
+	connector driver.Connector
+}
+
+func (d connectorDriver) Open(string) (driver.Conn, error) {
+	conn, err := d.connector.Connect(d.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wrapCountingConn(conn), nil
+}
+
+// hookedConnector ครอบทุก connection ที่ได้จาก connector ด้วย sqlhooks
+// ใช้ได้ทั้งกับ pgx pool และ driver อื่นที่เปิดผ่าน DSN
+type hookedConnector struct {
+	connector driver.Connector
+	hooks     sqlhooks.Hooks
+}
+
+func (c *hookedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return sqlhooks.Wrap(connectorDriver{ctx: ctx, connector: c.connector}, c.hooks).Open("")
+}
+
+func (c *hookedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// dsnConnector ใช้กับ driver ที่ไม่ implement driver.DriverContext
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// newDriverConnector สร้าง connector ของ driver ที่ลงทะเบียนไว้กับ database/sql โดยไม่ต้อง sql.Register ซ้ำ
+func newDriverConnector(driverName string, dsn string) (driver.Connector, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	db.Close()
+
+	if driverCtx, ok := drv.(driver.DriverContext); ok {
+		return driverCtx.OpenConnector(dsn)
+	}
+	return dsnConnector{dsn: dsn, driver: drv}, nil
+}
+
+// wrapConnector ครอบ connector ด้วย hooks ถ้ามี
+func wrapConnector(connector driver.Connector, hooks []sqlhooks.Hooks) driver.Connector {
+	switch len(hooks) {
+	case 0:
+		return connector
+	case 1:
+		return &hookedConnector{connector: connector, hooks: hooks[0]}
+	}
+	return &hookedConnector{connector: connector, hooks: sqlhooks.Compose(hooks...)}
+}
+
+// countingConn อยู่ใต้ sqlhooks จึงได้รับ ctx ที่ผ่าน Before ของ hook แล้ว
+// ใช้นับจำนวนแถวของ query และ RowsAffected ของ exec ให้ QueryStatsHook (ดู queryObservation)
+type countingConn struct {
+	conn driver.Conn
+}
+
+// wrapCountingConn ครอบเฉพาะ connection ที่รองรับ interface แบบ context ครบ
+// ถ้าไม่ครบจะคืน connection เดิมและ QueryStatsHook จะไม่รู้จำนวนแถว
+func wrapCountingConn(conn driver.Conn) driver.Conn {
+	_, execer := conn.(driver.ExecerContext)
+	_, queryer := conn.(driver.QueryerContext)
+	_, beginTx := conn.(driver.ConnBeginTx)
+	if !execer || !queryer || !beginTx {
+		return conn
+	}
+	return &countingConn{conn: conn}
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *countingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if prepare, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = prepare.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &countingStmt{stmt: stmt}, nil
+}
+
+func (c *countingConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *countingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	observeResult(ctx, result, err)
+	return result, err
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	return observeRows(ctx, rows, err), err
+}
+
+func (c *countingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+type countingStmt struct {
+	stmt driver.Stmt
+}
+
+func (s *countingStmt) Close() error  { return s.stmt.Close() }
+func (s *countingStmt) NumInput() int { return s.stmt.NumInput() }
+
+func (s *countingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.stmt.Exec(args)
+}
+
+func (s *countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.stmt.Query(args)
+}
+
+func (s *countingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	var err error
+	if exec, ok := s.stmt.(driver.StmtExecContext); ok {
+		result, err = exec.ExecContext(ctx, args)
+	} else {
+		result, err = s.stmt.Exec(namedValues(args))
+	}
+	observeResult(ctx, result, err)
+	return result, err
+}
+
+func (s *countingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if query, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = query.QueryContext(ctx, args)
+	} else {
+		rows, err = s.stmt.Query(namedValues(args))
+	}
+	return observeRows(ctx, rows, err), err
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for _, arg := range args {
+		values[arg.Ordinal-1] = arg.Value
+	}
+	return values
+}
+
+func observeResult(ctx context.Context, result driver.Result, err error) {
+	observation, ok := ctx.Value(queryObservationKey{}).(*queryObservation)
+	if !ok || err != nil || result == nil {
+		return
+	}
+	if affected, err := result.RowsAffected(); err == nil {
+		observation.rows = affected
+	}
+}
+
+// observeRows ครอบ rows เพื่อนับแถวที่อ่าน สถิติของ query จะถูกบันทึกตอน rows ถูกปิด
+func observeRows(ctx context.Context, rows driver.Rows, err error) driver.Rows {
+	observation, ok := ctx.Value(queryObservationKey{}).(*queryObservation)
+	if !ok || err != nil || rows == nil {
+		return rows
+	}
+	observation.deferred = true
+	return &countingRows{Rows: rows, observation: observation}
+}
+
+// countingRows ส่งต่อ interface เสริมของ driver.Rows ด้วยค่าเริ่มต้นแบบเดียวกับ database/sql
+type countingRows struct {
+	driver.Rows
+	observation *queryObservation
+	count       int64
+}
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.count++
+	}
+	return err
+}
+
+func (r *countingRows) Close() error {
+	err := r.Rows.Close()
+	r.observation.finish(r.count)
+	return err
+}
+
+func (r *countingRows) HasNextResultSet() bool {
+	if next, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return next.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *countingRows) NextResultSet() error {
+	if next, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return next.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *countingRows) ColumnTypeScanType(index int) reflect.Type {
+	if column, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return column.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *countingRows) ColumnTypeDatabaseTypeName(index int) string {
+	if column, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return column.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *countingRows) ColumnTypeLength(index int) (int64, bool) {
+	if column, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return column.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *countingRows) ColumnTypeNullable(index int) (bool, bool) {
+	if column, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return column.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *countingRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if column, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return column.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
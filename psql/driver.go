@@ -2,8 +2,22 @@ package psql
 
 type Driver string
 
-const opentracing_postgres = "ot_pg"
 const Mssql Driver = "sqlserver" // sqlserver
 const Postgres Driver = "pgx"    // postgresql
 const Clickhouse Driver = "clickhouse"
 const MySQL Driver = "mysql"
+
+// dbSystem คืนชื่อระบบฐานข้อมูลตาม OpenTelemetry semantic conventions (db.system)
+func dbSystem(driver Driver) string {
+	switch driver {
+	case Postgres:
+		return "postgresql"
+	case Mssql:
+		return "mssql"
+	case MySQL:
+		return "mysql"
+	case Clickhouse:
+		return "clickhouse"
+	}
+	return "other_sql"
+}
@@ -11,22 +11,54 @@ import (
 	"github.com/spf13/cast"
 )
 
+var (
+	tableNameReg = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE|JOIN)\s+([\w."\[\]` + "`" + `]+)`)
+	selectReg    = regexp.MustCompile(`SELECT`)
+	insertReg    = regexp.MustCompile(`INSERT\s+INTO`)
+	updateReg    = regexp.MustCompile(`UPDATE\s+.+\s+SET`)
+	deleteReg    = regexp.MustCompile(`DELETE\s+FROM`)
+)
+
 type TracingHook struct {
-	tracer opentracing.Tracer
+	tracer   opentracing.Tracer
+	dbSystem string
+	redact   redactPolicy
 }
 
-func NewTracingHook(tracing opentracing.Tracer) *TracingHook {
-	return &TracingHook{
+// NewTracingHook สร้าง hook ที่ log query และ args ลง span
+// args จะถูก redact ตาม DefaultRedactPatterns และ DefaultMaskedColumns เว้นแต่กำหนด option อื่น
+// ถ้า tracing เป็น nil จะใช้ opentracing.GlobalTracer()
+func NewTracingHook(tracing opentracing.Tracer, opts ...TracingHookOption) *TracingHook {
+	if tracing == nil {
+		tracing = opentracing.GlobalTracer()
+	}
+	hook := &TracingHook{
 		tracer: tracing,
+		redact: defaultRedactPolicy(),
 	}
+	for _, opt := range opts {
+		opt(hook)
+	}
+	return hook
+}
+
+func newTracingHook(tracing opentracing.Tracer, driver Driver, opts ...TracingHookOption) *TracingHook {
+	hook := NewTracingHook(tracing, opts...)
+	hook.dbSystem = dbSystem(driver)
+	return hook
 }
 
-func (h *TracingHook) getOperationName(query string) string {
+// getTableName คืนชื่อตารางแรกที่ query อ้างถึง หรือค่าว่างถ้าหาไม่พบ
+func getTableName(query string) string {
+	match := tableNameReg.FindStringSubmatch(query)
+	if match == nil {
+		return ""
+	}
+	return strings.Trim(match[1], "`\"[]")
+}
+
+func getOperationName(query string) string {
 	defaultOperationName := "database"
-	selectReg := regexp.MustCompile(`SELECT`)
-	insertReg := regexp.MustCompile(`INSERT\s+INTO`)
-	updateReg := regexp.MustCompile(`UPDATE\s+.+\s+SET`)
-	deleteReg := regexp.MustCompile(`DELETE\s+FROM`)
 
 	query = strings.ToUpper(query)
 	selectIndex := selectReg.FindStringIndex(query)
@@ -59,16 +91,26 @@ func (h *TracingHook) Before(ctx context.Context, query string, args ...interfac
 	if ctx != nil {
 		span := opentracing.SpanFromContext(ctx)
 		if span != nil {
-			span, ctx = opentracing.StartSpanFromContext(ctx, "database", opentracing.ChildOf(span.Context()))
-			span.SetTag("operation", h.getOperationName(query))
+			operation := getOperationName(query)
+			// ใช้ tracer ของ hook แทน global tracer
+			span = h.tracer.StartSpan("database", opentracing.ChildOf(span.Context()))
+			ctx = opentracing.ContextWithSpan(ctx, span)
+			span.SetTag("operation", operation)
+			span.SetTag("db.operation", operation)
+			if h.dbSystem != "" {
+				span.SetTag("db.system", h.dbSystem)
+			}
+			if table := getTableName(query); table != "" {
+				span.SetTag("db.sql.table", table)
+			}
 			span.LogFields(
 				otlog.String("statement", query),
 			)
 
 			if args != nil && len(args) > 0 {
 				var argsString = []string{}
-				for index, arg := range args {
-					argsString = append(argsString, fmt.Sprintf(`$$%s:%s`, cast.ToString(index+1), cast.ToString(arg)))
+				for index, arg := range h.redact.apply(query, args) {
+					argsString = append(argsString, fmt.Sprintf(`$$%s:%s`, cast.ToString(index+1), arg))
 				}
 				span.LogFields(
 					otlog.String("args", strings.Join(argsString, ",")),
@@ -0,0 +1,601 @@
+package psql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultMigrationTable = "schema_migrations"
+
+// ชื่อไฟล์ migration ต้องเป็น <version>_<name>.up.sql และ <version>_<name>.down.sql
+// เช่น 20250101120000_create_users.up.sql
+var migrationFileReg = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var mssqlBatchSeparatorReg = regexp.MustCompile(`(?im)^\s*GO\s*$`)
+
+// nonTransactionalReg จับคำสั่งที่รันใน transaction ไม่ได้ เช่น CREATE INDEX CONCURRENTLY ของ Postgres
+// ใช้กับ script ที่ผ่าน stripSQL แล้ว และไม่จับ REFRESH MATERIALIZED VIEW CONCURRENTLY ซึ่งรันใน transaction ได้
+var nonTransactionalReg = regexp.MustCompile(`(?i)\b(?:(?:CREATE\s+(?:UNIQUE\s+)?INDEX|DROP\s+INDEX|REINDEX\s+(?:\([^)]*\)\s*)?\w+|DETACH\s+PARTITION\s+\S+)\s+CONCURRENTLY|VACUUM|(?:CREATE|DROP)\s+DATABASE|ALTER\s+SYSTEM)\b`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	UpSQL    string
+	DownSQL  string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// ChecksumMismatch คือไฟล์ up ถูกแก้หลังจาก migrate ไปแล้ว
+	ChecksumMismatch bool `json:"checksum_mismatch,omitempty"`
+}
+
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Migrator รัน SQL migration จาก fs.FS (เช่น embed.FS) และบันทึก version ลงตาราง schema
+// แต่ละไฟล์รันใน transaction เดียวกับการบันทึก version ยกเว้นไฟล์ที่มีคำสั่งที่รันใน transaction ไม่ได้
+// (CONCURRENTLY, VACUUM, CREATE/DROP DATABASE, ALTER SYSTEM) ซึ่งจะรันทีละ statement นอก transaction
+// ถ้าไฟล์แบบนี้ล้มกลางทาง statement ที่รันไปแล้วจะไม่ถูกย้อน จึงควรแยกไว้ไฟล์ละหนึ่ง statement
+type Migrator struct {
+	client *Client
+	fsys   fs.FS
+	dir    string
+	table  string
+}
+
+type MigrateOption func(*Migrator)
+
+// WithMigrationDir กำหนด directory ภายใน fs ที่เก็บไฟล์ migration ค่าเริ่มต้นคือ "."
+func WithMigrationDir(dir string) MigrateOption {
+	return func(m *Migrator) {
+		m.dir = dir
+	}
+}
+
+// WithMigrationTable กำหนดชื่อตารางที่เก็บ version ค่าเริ่มต้นคือ schema_migrations
+func WithMigrationTable(table string) MigrateOption {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+func NewMigrator(client *Client, fsys fs.FS, opts ...MigrateOption) *Migrator {
+	m := &Migrator{
+		client: client,
+		fsys:   fsys,
+		dir:    ".",
+		table:  DefaultMigrationTable,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Migrate รัน migration ที่ยังไม่ได้รันทั้งหมด
+func (c *Client) Migrate(ctx context.Context, fsys fs.FS, opts ...MigrateOption) error {
+	return NewMigrator(c, fsys, opts...).Up(ctx)
+}
+
+// Load อ่านไฟล์ migration ทั้งหมดเรียงตาม version
+func (m *Migrator) Load() ([]*Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, m.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration dir: %v", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileReg.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %v", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(m.fsys, path.Join(m.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", entry.Name(), err)
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			migration.UpSQL = string(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up รัน migration ที่ยังไม่ได้รันทั้งหมดตามลำดับ
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		return m.up(ctx, conn, -1)
+	})
+}
+
+// Down ย้อน migration ล่าสุด steps ตัว
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		return m.down(ctx, conn, steps)
+	})
+}
+
+// Redo ย้อน migration ล่าสุดแล้วรันใหม่
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		if err := m.down(ctx, conn, 1); err != nil {
+			return err
+		}
+		return m.up(ctx, conn, 1)
+	})
+}
+
+// Status ดูสถานะของทุก migration
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ensureTable(ctx, m.client.db); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, m.client.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.ChecksumMismatch = record.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) up(ctx context.Context, conn *sqlx.Conn, limit int) error {
+	migrations, err := m.Load()
+	if err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if record, ok := applied[migration.Version]; ok {
+			if record.Checksum != migration.Checksum {
+				return fmt.Errorf("checksum mismatch for applied migration %d_%s", migration.Version, migration.Name)
+			}
+			continue
+		}
+		if limit >= 0 && count >= limit {
+			break
+		}
+
+		insert := conn.Rebind(fmt.Sprintf(`INSERT INTO %s (version, name, checksum) VALUES (?, ?, ?)`, m.table))
+		err := m.exec(ctx, conn, migration.UpSQL, insert, migration.Version, migration.Name, migration.Checksum)
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %v", migration.Version, migration.Name, err)
+		}
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		count++
+	}
+	return nil
+}
+
+func (m *Migrator) down(ctx context.Context, conn *sqlx.Conn, steps int) error {
+	migrations, err := m.Load()
+	if err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	// ตรวจทุกตัวที่จะย้อนก่อน เพื่อไม่ให้ย้อนไปครึ่งทางแล้วเจอตัวที่ไม่มีไฟล์ down
+	targets := []*Migration{}
+	for i := len(migrations) - 1; i >= 0 && len(targets) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if strings.TrimSpace(migration.DownSQL) == "" {
+			return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+		targets = append(targets, migration)
+	}
+
+	for _, migration := range targets {
+		remove := conn.Rebind(fmt.Sprintf(`DELETE FROM %s WHERE version = ?`, m.table))
+		if err := m.exec(ctx, conn, migration.DownSQL, remove, migration.Version); err != nil {
+			return fmt.Errorf("failed to rollback migration %d_%s: %v", migration.Version, migration.Name, err)
+		}
+		log.Printf("Rolled back migration %d_%s", migration.Version, migration.Name)
+	}
+	return nil
+}
+
+// exec รัน script ของ migration แล้วบันทึก version ใน transaction เดียวกัน
+// ClickHouse ไม่มี transaction และ script ที่มีคำสั่งแบบ non-transactional จึงรันทีละ statement
+func (m *Migrator) exec(ctx context.Context, conn *sqlx.Conn, script string, record string, args ...interface{}) error {
+	driver := Driver(m.client.driverName)
+	statements := splitMigrationStatements(driver, script)
+
+	nonTransactional := driver != Clickhouse && nonTransactionalReg.MatchString(stripSQL(driver, script))
+	if nonTransactional {
+		log.Printf("Warning: migration contains statements that cannot run in a transaction, running without transaction")
+		if driver == Postgres {
+			// simple protocol ของ Postgres รันหลาย statement ใน implicit transaction จึงต้องแยกเอง
+			statements = dropEmptyStatements(splitOnSemicolon(driver, script))
+		}
+	}
+
+	if driver == Clickhouse || nonTransactional {
+		for _, statement := range statements {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		_, err := conn.ExecContext(ctx, record, args...)
+		return err
+	}
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, db sqlx.ExecerContext) error {
+	var ddl string
+	switch Driver(m.client.driverName) {
+	case Mssql:
+		ddl = fmt.Sprintf(`IF OBJECT_ID(N'%[1]s', N'U') IS NULL CREATE TABLE %[1]s (
+			version BIGINT PRIMARY KEY,
+			name NVARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()
+		)`, m.table)
+	case MySQL:
+		ddl = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`, m.table)
+	case Clickhouse:
+		ddl = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			version Int64,
+			name String,
+			checksum String,
+			applied_at DateTime DEFAULT now()
+		) ENGINE = MergeTree ORDER BY version`, m.table)
+	default:
+		ddl = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`, m.table)
+	}
+
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("failed to create migration table: %v", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context, db sqlx.QueryerContext) (map[int64]appliedMigration, error) {
+	records := []appliedMigration{}
+	query := fmt.Sprintf(`SELECT version, name, checksum, applied_at FROM %s ORDER BY version`, m.table)
+	if err := sqlx.SelectContext(ctx, db, &records, query); err != nil {
+		return nil, fmt.Errorf("failed to load applied migrations: %v", err)
+	}
+
+	applied := make(map[int64]appliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// withLock จอง connection เดียวแล้วล็อกด้วย advisory lock ของแต่ละฐานข้อมูล
+// เพื่อไม่ให้หลาย instance migrate พร้อมกัน
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.client.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	h := fnv.New64a()
+	h.Write([]byte(m.table))
+	lockKey := int64(h.Sum64())
+	lockName := "migrate:" + m.table
+
+	// acquired ตรวจค่าที่ lock คืนมา ถ้าเป็น nil คือ lock รอจนได้เสมอและไม่มีค่าให้ตรวจ
+	var lock, unlock string
+	var lockArgs []interface{}
+	var acquired func(result int64) bool
+	switch Driver(m.client.driverName) {
+	case Postgres:
+		lock, unlock, lockArgs = `SELECT pg_advisory_lock($1)`, `SELECT pg_advisory_unlock($1)`, []interface{}{lockKey}
+	case MySQL:
+		// GET_LOCK คืน 1 เมื่อได้ lock, 0 เมื่อหมดเวลา และ NULL เมื่อเกิด error
+		lock, unlock, lockArgs = `SELECT GET_LOCK(?, -1)`, `SELECT RELEASE_LOCK(?)`, []interface{}{lockName}
+		acquired = func(result int64) bool { return result == 1 }
+	case Mssql:
+		// sp_getapplock คืนค่า >= 0 เมื่อได้ lock และค่าติดลบเมื่อหมดเวลา ถูก deadlock หรือเกิด error
+		lock = `DECLARE @result int; EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = -1; SELECT @result`
+		unlock = `EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'`
+		lockArgs = []interface{}{lockName}
+		acquired = func(result int64) bool { return result >= 0 }
+	default:
+		log.Printf("Warning: %s does not support advisory locks, migrations are not protected from concurrent runs", m.client.driverName)
+	}
+
+	if lock != "" {
+		if acquired == nil {
+			if _, err := conn.ExecContext(ctx, lock, lockArgs...); err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %v", err)
+			}
+		} else {
+			var result sql.NullInt64
+			if err := conn.QueryRowxContext(ctx, lock, lockArgs...).Scan(&result); err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %v", err)
+			}
+			if !result.Valid || !acquired(result.Int64) {
+				return fmt.Errorf("failed to acquire migration lock: lock returned %v", formatLockResult(result))
+			}
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), unlock, lockArgs...); err != nil {
+				log.Printf("Warning: failed to release migration lock: %v", err)
+			}
+		}()
+	}
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func formatLockResult(result sql.NullInt64) string {
+	if !result.Valid {
+		return "NULL"
+	}
+	return strconv.FormatInt(result.Int64, 10)
+}
+
+// splitMigrationStatements แยก script เป็น statement สำหรับ driver ที่ไม่รองรับหลาย statement ต่อครั้ง
+// Postgres รันทั้งไฟล์ได้ในครั้งเดียว MSSQL แยกด้วยบรรทัด GO ส่วน MySQL และ ClickHouse แยกด้วย ;
+func splitMigrationStatements(driver Driver, script string) []string {
+	var parts []string
+	switch driver {
+	case Postgres:
+		parts = []string{script}
+	case Mssql:
+		parts = mssqlBatchSeparatorReg.Split(script, -1)
+	default:
+		parts = splitOnSemicolon(driver, script)
+	}
+	return dropEmptyStatements(parts)
+}
+
+func dropEmptyStatements(parts []string) []string {
+	statements := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			statements = append(statements, part)
+		}
+	}
+	return statements
+}
+
+// splitOnSemicolon แยก ; ที่อยู่นอก string, dollar quote และ comment
+func splitOnSemicolon(driver Driver, script string) []string {
+	var statements []string
+	start := 0
+	runes := []rune(script)
+	scanSQL(driver, runes, func(i int, token sqlToken) {
+		if token == sqlCode && runes[i] == ';' {
+			statements = append(statements, string(runes[start:i]))
+			start = i + 1
+		}
+	})
+	return append(statements, string(runes[start:]))
+}
+
+// stripSQL แทน comment ด้วยช่องว่างและ string หรือ quoted identifier ด้วย x
+// เพื่อให้ regex จับได้เฉพาะคำสั่งจริง โดยที่ identifier ที่ถูก quote ยังนับเป็นหนึ่งคำ
+func stripSQL(driver Driver, script string) string {
+	runes := []rune(script)
+	var b strings.Builder
+	b.Grow(len(script))
+	scanSQL(driver, runes, func(i int, token sqlToken) {
+		switch token {
+		case sqlComment:
+			b.WriteRune(' ')
+		case sqlLiteral:
+			b.WriteRune('x')
+		default:
+			b.WriteRune(runes[i])
+		}
+	})
+	return b.String()
+}
+
+type sqlToken int
+
+const (
+	sqlCode sqlToken = iota
+	sqlComment
+	sqlLiteral
+)
+
+// scanSQL เรียก fn กับทุกตัวอักษรของ script พร้อมบอกว่าอยู่ใน comment, string (รวม dollar quote ของ Postgres)
+// หรือเป็นคำสั่ง # เป็น comment เฉพาะ MySQL และ backslash escape ใน string มีเฉพาะ MySQL
+func scanSQL(driver Driver, runes []rune, fn func(i int, token sqlToken)) {
+	var quote rune
+	var dollarTag []rune
+	lineComment, blockComment := false, false
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case lineComment:
+			if r == '\n' {
+				lineComment = false
+				fn(i, sqlCode)
+			} else {
+				fn(i, sqlComment)
+			}
+		case blockComment:
+			fn(i, sqlComment)
+			if r == '*' && next == '/' {
+				blockComment = false
+				i++
+				fn(i, sqlComment)
+			}
+		case dollarTag != nil:
+			if hasRunePrefix(runes[i:], dollarTag) {
+				for end := i + len(dollarTag); i < end; i++ {
+					fn(i, sqlLiteral)
+				}
+				i--
+				dollarTag = nil
+			} else {
+				fn(i, sqlLiteral)
+			}
+		case quote != 0:
+			fn(i, sqlLiteral)
+			if driver == MySQL && r == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				fn(i, sqlLiteral)
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '-' && next == '-', r == '#' && driver == MySQL:
+			lineComment = true
+			fn(i, sqlComment)
+		case r == '/' && next == '*':
+			blockComment = true
+			fn(i, sqlComment)
+			i++
+			fn(i, sqlComment)
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			fn(i, sqlLiteral)
+		case r == '$' && driver == Postgres && dollarQuoteTag(runes, i) != nil:
+			dollarTag = dollarQuoteTag(runes, i)
+			for end := i + len(dollarTag); i < end; i++ {
+				fn(i, sqlLiteral)
+			}
+			i--
+		default:
+			fn(i, sqlCode)
+		}
+	}
+}
+
+// dollarQuoteTag คืน $tag$ ที่เริ่มที่ตำแหน่ง i หรือ nil ถ้าไม่ใช่ dollar quote เช่น $1 หรือ $ ในชื่อ identifier
+func dollarQuoteTag(runes []rune, i int) []rune {
+	if i > 0 && (runes[i-1] == '_' || runes[i-1] == '$' || unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+		return nil
+	}
+	for j := i + 1; j < len(runes); j++ {
+		r := runes[j]
+		switch {
+		case r == '$':
+			return runes[i : j+1]
+		case r == '_' || unicode.IsLetter(r):
+		case unicode.IsDigit(r) && j > i+1:
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func hasRunePrefix(runes, prefix []rune) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if runes[i] != r {
+			return false
+		}
+	}
+	return true
+}
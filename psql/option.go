@@ -0,0 +1,257 @@
+package psql
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GodeFvt/go-backend/helper"
+	"github.com/go-sql-driver/mysql"
+	"github.com/opentracing/opentracing-go"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/spf13/cast"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultPostgresMaxOpenConns คือจำนวน connection เริ่มต้นของ Postgres ถ้าไม่ได้กำหนด
+const DefaultPostgresMaxOpenConns = 4
+
+type clientOptions struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+
+	poolMinConns      int32
+	poolMaxConns      int32
+	healthCheckPeriod time.Duration
+
+	statementTimeout time.Duration
+	applicationName  string
+
+	connectRetries int
+	connectBackoff time.Duration
+
+	tracer      opentracing.Tracer
+	tracingOpts []TracingHookOption
+	otelTracer  trace.Tracer
+	hooks       []sqlhooks.Hooks
+
+	replicaStrategy      ReplicaStrategy
+	maxReplicaLag        time.Duration
+	replicaCheckInterval time.Duration
+
+	// err เก็บ error จาก option ที่อ่านค่าไม่สำเร็จ เช่น WithEnv
+	err error
+}
+
+// ClientOption ปรับการเชื่อมต่อของ NewConnection
+type ClientOption func(*clientOptions)
+
+// WithMaxOpenConns จำกัดจำนวน connection ที่เปิดพร้อมกัน
+func WithMaxOpenConns(n int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxOpenConns = n
+	}
+}
+
+// WithMaxIdleConns จำกัดจำนวน connection ที่ idle (ไม่มีผลกับ Postgres ซึ่ง pgxpool จัดการเอง)
+func WithMaxIdleConns(n int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxIdleConns = n
+	}
+}
+
+// WithConnMaxLifetime กำหนดอายุสูงสุดของ connection
+func WithConnMaxLifetime(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.connMaxLifetime = d
+	}
+}
+
+// WithConnMaxIdleTime กำหนดเวลาที่ connection idle ได้ก่อนถูกปิด
+func WithConnMaxIdleTime(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.connMaxIdleTime = d
+	}
+}
+
+// WithPoolConns กำหนดจำนวน connection ต่ำสุดและสูงสุดของ pgxpool (Postgres เท่านั้น)
+func WithPoolConns(min int32, max int32) ClientOption {
+	return func(o *clientOptions) {
+		o.poolMinConns = min
+		o.poolMaxConns = max
+	}
+}
+
+// WithHealthCheckPeriod กำหนดรอบการตรวจ connection ที่ idle ของ pgxpool (Postgres เท่านั้น)
+func WithHealthCheckPeriod(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.healthCheckPeriod = d
+	}
+}
+
+// WithStatementTimeout กำหนด timeout เริ่มต้นของทุก statement
+// ใช้ statement_timeout ของ Postgres และ max_execution_time ของ MySQL (มีผลกับ SELECT เท่านั้น)
+func WithStatementTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.statementTimeout = d
+	}
+}
+
+// WithApplicationName ตั้งชื่อ application ที่เห็นใน pg_stat_activity หรือ sys.dm_exec_sessions
+func WithApplicationName(name string) ClientOption {
+	return func(o *clientOptions) {
+		o.applicationName = name
+	}
+}
+
+// WithConnectRetry ลองเชื่อมต่อใหม่สูงสุด retries ครั้งถ้าเชื่อมต่อครั้งแรกไม่สำเร็จ
+// โดยรอ backoff และเพิ่มเป็นสองเท่าทุกครั้ง
+func WithConnectRetry(retries int, backoff time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.connectRetries = retries
+		o.connectBackoff = backoff
+	}
+}
+
+// WithTracer trace ทุก query ด้วย opentracing TracingHook โดย opts ใช้ปรับการ redact args
+func WithTracer(tracer opentracing.Tracer, opts ...TracingHookOption) ClientOption {
+	return func(o *clientOptions) {
+		o.tracer = tracer
+		o.tracingOpts = opts
+	}
+}
+
+// WithOTelTracer trace ทุก query ด้วย OpenTelemetry
+func WithOTelTracer(tracer trace.Tracer) ClientOption {
+	return func(o *clientOptions) {
+		o.otelTracer = tracer
+	}
+}
+
+// WithHooks เพิ่ม sqlhooks ของผู้ใช้ ทำงานหลัง tracing hook
+func WithHooks(hooks ...sqlhooks.Hooks) ClientOption {
+	return func(o *clientOptions) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
+
+// WithEnv อ่านค่า pool จาก environment ที่ขึ้นต้นด้วย prefix เช่น prefix "DB_" จะอ่าน
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME,
+// DB_POOL_MIN_CONNS, DB_POOL_MAX_CONNS, DB_HEALTH_CHECK_PERIOD, DB_STATEMENT_TIMEOUT,
+// DB_APPLICATION_NAME, DB_CONNECT_RETRIES และ DB_CONNECT_BACKOFF
+// ค่าเวลาใช้รูปแบบของ time.ParseDuration เช่น "30s" ถ้าเป็นตัวเลขล้วนจะนับเป็นวินาที ค่าที่ไม่ได้ตั้งจะไม่เปลี่ยน
+// ถ้าค่าไหนแปลงไม่ได้ NewConnection จะคืน error แทนการใช้ค่า 0
+func WithEnv(prefix string) ClientOption {
+	return func(o *clientOptions) {
+		e := &envReader{prefix: prefix}
+		e.int("MAX_OPEN_CONNS", &o.maxOpenConns)
+		e.int("MAX_IDLE_CONNS", &o.maxIdleConns)
+		e.duration("CONN_MAX_LIFETIME", &o.connMaxLifetime)
+		e.duration("CONN_MAX_IDLE_TIME", &o.connMaxIdleTime)
+		e.int32("POOL_MIN_CONNS", &o.poolMinConns)
+		e.int32("POOL_MAX_CONNS", &o.poolMaxConns)
+		e.duration("HEALTH_CHECK_PERIOD", &o.healthCheckPeriod)
+		e.duration("STATEMENT_TIMEOUT", &o.statementTimeout)
+		if v := e.get("APPLICATION_NAME"); v != "" {
+			o.applicationName = v
+		}
+		e.int("CONNECT_RETRIES", &o.connectRetries)
+		e.duration("CONNECT_BACKOFF", &o.connectBackoff)
+
+		if e.err != nil {
+			o.err = errors.Join(o.err, e.err)
+		}
+	}
+}
+
+// envReader อ่านค่าจาก environment ตาม prefix และสะสม error ของค่าที่แปลงไม่ได้
+type envReader struct {
+	prefix string
+	err    error
+}
+
+func (e *envReader) get(name string) string {
+	return helper.GetENV(e.prefix+name, "")
+}
+
+func (e *envReader) fail(name, value string, err error) {
+	e.err = errors.Join(e.err, fmt.Errorf("invalid %s%s %q: %v", e.prefix, name, value, err))
+}
+
+func (e *envReader) int(name string, dst *int) {
+	if v := e.get(name); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e.fail(name, v, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) int32(name string, dst *int32) {
+	if v := e.get(name); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			e.fail(name, v, err)
+			return
+		}
+		*dst = int32(n)
+	}
+}
+
+func (e *envReader) duration(name string, dst *time.Duration) {
+	if v := e.get(name); v != "" {
+		d, err := parseEnvDuration(v)
+		if err != nil {
+			e.fail(name, v, err)
+			return
+		}
+		*dst = d
+	}
+}
+
+// parseEnvDuration แปลงค่าเวลาจาก environment ตัวเลขล้วนนับเป็นวินาที นอกนั้นใช้ time.ParseDuration
+func parseEnvDuration(v string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// applyDSNOptions ใส่ statement timeout และ application name ลงใน DSN ของ driver ที่ไม่ใช่ Postgres
+func applyDSNOptions(databaseType Driver, dsn string, options *clientOptions) (string, error) {
+	switch databaseType {
+	case MySQL:
+		if options.statementTimeout <= 0 {
+			return dsn, nil
+		}
+		config, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "", err
+		}
+		if config.Params == nil {
+			config.Params = map[string]string{}
+		}
+		config.Params["max_execution_time"] = cast.ToString(options.statementTimeout.Milliseconds())
+		return config.FormatDSN(), nil
+	case Mssql:
+		if options.applicationName == "" || !strings.HasPrefix(dsn, "sqlserver://") {
+			return dsn, nil
+		}
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		query.Set("app name", options.applicationName)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+	return dsn, nil
+}
@@ -0,0 +1,62 @@
+package psql
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTelHook สร้าง span ด้วย OpenTelemetry ต่อ query ที่มี span แม่อยู่ใน context
+type OTelHook struct {
+	tracer   trace.Tracer
+	dbSystem string
+}
+
+func NewOTelHook(tracer trace.Tracer, driver Driver) *OTelHook {
+	return &OTelHook{
+		tracer:   tracer,
+		dbSystem: dbSystem(driver),
+	}
+}
+
+func (h *OTelHook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+
+	operation := getOperationName(query)
+	table := getTableName(query)
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", h.dbSystem),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", query),
+	}
+	spanName := operation
+	if table != "" {
+		attrs = append(attrs, attribute.String("db.sql.table", table))
+		spanName = strings.Join([]string{operation, table}, " ")
+	}
+
+	ctx, _ = h.tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, nil
+}
+
+func (h *OTelHook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if ctx != nil {
+		trace.SpanFromContext(ctx).End()
+	}
+	return ctx, nil
+}
+
+func (h *OTelHook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	if ctx != nil {
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+	}
+	return err
+}
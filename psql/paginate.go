@@ -0,0 +1,348 @@
+package psql
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/GodeFvt/go-backend/helper/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Paginate รัน query แบบแบ่งหน้าตาม page แล้วตั้งค่า TotalEntrySizes/TotalPages ให้ page
+// query ใช้ ? เป็น placeholder (จะถูก Rebind ตาม driver) และควรมี ORDER BY เพื่อให้ผลลัพธ์คงที่
+// query ต้องไม่มี LIMIT, OFFSET หรือ FETCH ชั้นนอกสุด เพราะ Paginate ต่อท้ายเอง (ถ้ามีจะคืน error)
+// จำนวนแถวทั้งหมดนับด้วย COUNT(*) อีก query แยกจากหน้าข้อมูลเพื่อให้ใช้ได้กับทุก driver
+func Paginate[T any](ctx context.Context, c *Client, query string, args []interface{}, page *models.Paginator) ([]T, error) {
+	if page.Page <= 0 {
+		page.Page = 1
+	}
+	if page.PerPage <= 0 {
+		page.PerPage = models.NewPaginator().PerPage
+	}
+
+	base, orderBy, err := splitOrderBy(query)
+	if err != nil {
+		return nil, err
+	}
+
+	db := c.Reader(ctx)
+
+	var total int64
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (%s) paginate_q", base)
+	if err := db.GetContext(ctx, &total, db.Rebind(countQuery), args...); err != nil {
+		return nil, fmt.Errorf("failed to count rows: %v", err)
+	}
+	page.SetPaginatorByAllRows(int(total))
+
+	var pageQuery string
+	pageArgs := append([]interface{}{}, args...)
+	if Driver(c.driverName) == Mssql {
+		// OFFSET/FETCH ของ MSSQL ต้องมี ORDER BY เสมอ
+		if orderBy == "" {
+			orderBy = "ORDER BY (SELECT NULL)"
+		}
+		pageQuery = fmt.Sprintf("%s %s OFFSET ? ROWS FETCH NEXT ? ROWS ONLY", base, orderBy)
+		pageArgs = append(pageArgs, page.GetOffset(), page.GetLimit())
+	} else {
+		pageQuery = fmt.Sprintf("%s %s LIMIT ? OFFSET ?", base, orderBy)
+		pageArgs = append(pageArgs, page.GetLimit(), page.GetOffset())
+	}
+
+	items := []T{}
+	if err := db.SelectContext(ctx, &items, db.Rebind(pageQuery), pageArgs...); err != nil {
+		return nil, fmt.Errorf("failed to query page: %v", err)
+	}
+	return items, nil
+}
+
+// CursorPage ใช้กับ PaginateCursor
+type CursorPage struct {
+	// Columns คือชื่อ column (ตาม db tag ของ T) ที่ใช้เป็น keyset เรียงจากหลักไปรอง
+	// column สุดท้ายควร unique เช่น []string{"created_at", "id"}
+	Columns []string
+	// Desc เรียงจากมากไปน้อย
+	Desc bool
+	// Limit จำนวนแถวต่อหน้า
+	Limit int
+	// Cursor คือ token จาก NextCursor ของหน้าก่อน ค่าว่างคือหน้าแรก
+	Cursor string
+}
+
+// PaginateCursor แบ่งหน้าแบบ keyset ซึ่งเร็วกว่า OFFSET เมื่อข้อมูลเยอะ
+// query ต้องไม่มี ORDER BY (ถ้ามีจะถูกตัดออก) เพราะลำดับถูกกำหนดจาก Columns และต้องไม่มี LIMIT, OFFSET หรือ FETCH
+// Columns ต้องตรงกับ db tag ของ T ไม่เช่นนั้นจะคืน error
+// คืนแถวของหน้านี้และ cursor ของหน้าถัดไป หรือค่าว่างถ้าเป็นหน้าสุดท้าย
+func PaginateCursor[T any](ctx context.Context, c *Client, query string, args []interface{}, page CursorPage) ([]T, string, error) {
+	if len(page.Columns) == 0 {
+		return nil, "", fmt.Errorf("cursor columns are required")
+	}
+	if page.Limit <= 0 {
+		page.Limit = models.NewPaginator().PerPage
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, "", fmt.Errorf("cursor pagination requires a struct type, got %s", t)
+	}
+	fields := c.db.Mapper.TypeMap(t)
+	columns := make([]string, len(page.Columns))
+	for i, column := range page.Columns {
+		if fields.GetByPath(column) == nil {
+			return nil, "", fmt.Errorf("cursor column %s not found in %s", column, t)
+		}
+		columns[i] = quoteIdentifier(Driver(c.driverName), column)
+	}
+
+	base, _, err := splitOrderBy(query)
+	if err != nil {
+		return nil, "", err
+	}
+	queryArgs := append([]interface{}{}, args...)
+
+	where := ""
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, len(page.Columns))
+		if err != nil {
+			return nil, "", err
+		}
+		// ใช้รูป (a > ?) OR (a = ? AND b > ?) แทน row value เพราะ MSSQL ไม่รองรับ
+		op := ">"
+		if page.Desc {
+			op = "<"
+		}
+		conditions := make([]string, 0, len(columns))
+		for i, column := range columns {
+			parts := make([]string, 0, i+1)
+			for j := 0; j < i; j++ {
+				parts = append(parts, columns[j]+" = ?")
+				queryArgs = append(queryArgs, values[j])
+			}
+			parts = append(parts, column+" "+op+" ?")
+			queryArgs = append(queryArgs, values[i])
+			conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+		}
+		where = "WHERE " + strings.Join(conditions, " OR ")
+	}
+
+	direction := "ASC"
+	if page.Desc {
+		direction = "DESC"
+	}
+	orders := make([]string, len(columns))
+	for i, column := range columns {
+		orders[i] = column + " " + direction
+	}
+
+	// ดึงเกินหนึ่งแถวเพื่อดูว่ามีหน้าถัดไปหรือไม่
+	var cursorQuery string
+	if Driver(c.driverName) == Mssql {
+		cursorQuery = fmt.Sprintf("SELECT TOP (%d) * FROM (%s) cursor_q %s ORDER BY %s", page.Limit+1, base, where, strings.Join(orders, ", "))
+	} else {
+		cursorQuery = fmt.Sprintf("SELECT * FROM (%s) cursor_q %s ORDER BY %s LIMIT %d", base, where, strings.Join(orders, ", "), page.Limit+1)
+	}
+
+	db := c.Reader(ctx)
+	items := []T{}
+	if err := db.SelectContext(ctx, &items, db.Rebind(cursorQuery), queryArgs...); err != nil {
+		return nil, "", fmt.Errorf("failed to query page: %v", err)
+	}
+	if len(items) <= page.Limit {
+		return items, "", nil
+	}
+
+	items = items[:page.Limit]
+	next, err := encodeCursor(c, items[len(items)-1], page.Columns)
+	if err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
+
+// cursorField คือค่า keyset หนึ่งค่าใน cursor พร้อมชนิดที่ต้องแปลงกลับตอน decode
+// JSON ไม่แยก int กับ float และไม่มีชนิดเวลา จึงเก็บชนิดไว้ใน T แทนการเดาจากค่า
+type cursorField struct {
+	T string      `json:"t,omitempty"`
+	V interface{} `json:"v"`
+}
+
+const (
+	cursorTime  = "time"
+	cursorInt   = "int"
+	cursorUint  = "uint"
+	cursorFloat = "float"
+)
+
+// encodeCursor เก็บค่า keyset ของแถวสุดท้ายเป็น base64 ของ JSON array ของ cursorField
+func encodeCursor(c *Client, item interface{}, columns []string) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	if v.Kind() != reflect.Struct {
+		return "", fmt.Errorf("cursor pagination requires a struct type, got %s", v.Type())
+	}
+
+	values := make([]cursorField, len(columns))
+	for i, column := range columns {
+		field := c.db.Mapper.FieldByName(v, column)
+		if !field.IsValid() {
+			return "", fmt.Errorf("cursor column %s not found in %s", column, v.Type())
+		}
+		values[i] = cursorValue(field.Interface())
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// cursorValue ระบุชนิดของค่าให้ decodeCursor แปลงกลับได้ตรง เวลาทุกแบบเก็บเป็น RFC3339Nano
+// models.Timestamp marshal เป็น layout ที่ไม่มี timezone จึงต้องแปลงผ่าน ToTime ก่อน
+func cursorValue(value interface{}) cursorField {
+	switch v := value.(type) {
+	case time.Time:
+		return cursorField{T: cursorTime, V: v.Format(time.RFC3339Nano)}
+	case *time.Time:
+		if v != nil {
+			return cursorField{T: cursorTime, V: v.Format(time.RFC3339Nano)}
+		}
+		return cursorField{}
+	case models.Timestamp:
+		return cursorField{T: cursorTime, V: v.ToTime().Format(time.RFC3339Nano)}
+	case *models.Timestamp:
+		if v != nil {
+			return cursorField{T: cursorTime, V: v.ToTime().Format(time.RFC3339Nano)}
+		}
+		return cursorField{}
+	}
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return cursorField{}
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cursorField{T: cursorInt, V: rv.Int()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cursorField{T: cursorUint, V: rv.Uint()}
+	case reflect.Float32, reflect.Float64:
+		return cursorField{T: cursorFloat, V: rv.Float()}
+	}
+	return cursorField{V: value}
+}
+
+func decodeCursor(cursor string, size int) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// UseNumber เพื่อไม่ให้ key ที่เป็น int64 ขนาดใหญ่เสียความแม่นยำจากการแปลงเป็น float64
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	fields := []cursorField{}
+	if err := decoder.Decode(&fields); err != nil || len(fields) != size {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if field.T == "" {
+			values[i] = field.V
+			continue
+		}
+
+		var err error
+		switch field.T {
+		case cursorTime:
+			s, ok := field.V.(string)
+			if !ok {
+				return nil, ErrInvalidCursor
+			}
+			values[i], err = time.Parse(time.RFC3339Nano, s)
+		case cursorInt, cursorUint, cursorFloat:
+			n, ok := field.V.(json.Number)
+			if !ok {
+				return nil, ErrInvalidCursor
+			}
+			switch field.T {
+			case cursorInt:
+				values[i], err = n.Int64()
+			case cursorUint:
+				values[i], err = strconv.ParseUint(n.String(), 10, 64)
+			default:
+				values[i], err = n.Float64()
+			}
+		default:
+			return nil, ErrInvalidCursor
+		}
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+// splitOrderBy แยก ORDER BY ชั้นนอกสุดท้ายของ query ออกมา โดยไม่นับที่อยู่ในวงเล็บหรือ string
+// คืน error ถ้าชั้นนอกสุดมี LIMIT, OFFSET หรือ FETCH เพราะจะชนกับการแบ่งหน้าที่ต่อท้ายให้
+func splitOrderBy(query string) (string, string, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	upper := strings.ToUpper(query)
+
+	depth := 0
+	var quote byte
+	index := -1
+	for i := 0; i < len(upper); i++ {
+		ch := upper[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(upper[i:], "ORDER") && (i == 0 || !isIdentChar(upper[i-1])):
+			rest := strings.TrimLeftFunc(upper[i+len("ORDER"):], unicode.IsSpace)
+			if strings.HasPrefix(rest, "BY") {
+				index = i
+			}
+		case depth == 0 && (i == 0 || !isIdentChar(upper[i-1])):
+			for _, keyword := range paginationKeywords {
+				end := i + len(keyword)
+				if strings.HasPrefix(upper[i:], keyword) && (end == len(upper) || !isIdentChar(upper[end])) {
+					return "", "", fmt.Errorf("query must not contain %s, pagination is appended automatically", keyword)
+				}
+			}
+		}
+	}
+
+	if index < 0 {
+		return query, "", nil
+	}
+	return strings.TrimSpace(query[:index]), strings.TrimSpace(query[index:]), nil
+}
+
+var paginationKeywords = []string{"LIMIT", "OFFSET", "FETCH"}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch == '.' || (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9')
+}
@@ -0,0 +1,218 @@
+package psql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	DefaultMaxArgLength = 256
+	redactedValue       = "[REDACTED]"
+)
+
+var (
+	// DefaultRedactPatterns ปิดเลขบัตรประชาชน 13 หลักและอีเมลที่อยู่ใน args
+	DefaultRedactPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\b\d{13}\b`),
+		regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+	// DefaultMaskedColumns ชื่อ column ที่ค่าจะถูกปิดทั้งหมด เทียบแบบไม่สนตัวพิมพ์และตรงบางส่วน
+	DefaultMaskedColumns = []string{"password", "passwd", "secret", "token", "api_key", "citizen_id", "id_card"}
+
+	columnValueReg   = regexp.MustCompile(`([\w."\[\]` + "`" + `]+)\s*(?:=|<>|!=|<=|>=|<|>|(?i:\bI?LIKE\b))\s*(\$\d+|@p\d+|\?)`)
+	insertColumnsReg = regexp.MustCompile(`(?is)INSERT\s+INTO\s+[\w."\[\]` + "`" + `]+\s*\(([^)]*)\)\s*VALUES`)
+)
+
+type redactPolicy struct {
+	maxArgLength  int
+	patterns      []*regexp.Regexp
+	maskedColumns []string
+	typesOnly     bool
+}
+
+func defaultRedactPolicy() redactPolicy {
+	return redactPolicy{
+		maxArgLength:  DefaultMaxArgLength,
+		patterns:      DefaultRedactPatterns,
+		maskedColumns: DefaultMaskedColumns,
+	}
+}
+
+// TracingHookOption ปรับการ redact args ของ TracingHook
+type TracingHookOption func(*TracingHook)
+
+// WithMaxArgLength ตัดค่า arg ที่ยาวเกิน n ตัวอักษร ค่า 0 คือไม่ตัด
+func WithMaxArgLength(n int) TracingHookOption {
+	return func(h *TracingHook) {
+		h.redact.maxArgLength = n
+	}
+}
+
+// WithRedactPatterns แทนที่ DefaultRedactPatterns ส่วนของค่าที่ตรง pattern จะถูกแทนด้วย [REDACTED]
+func WithRedactPatterns(patterns ...*regexp.Regexp) TracingHookOption {
+	return func(h *TracingHook) {
+		h.redact.patterns = patterns
+	}
+}
+
+// WithMaskedColumns แทนที่ DefaultMaskedColumns ค่าที่ถูกใส่ลง column เหล่านี้ใน INSERT
+// หรือเทียบกับ column เหล่านี้ใน SET/WHERE จะถูกปิดทั้งหมด
+func WithMaskedColumns(columns ...string) TracingHookOption {
+	return func(h *TracingHook) {
+		h.redact.maskedColumns = columns
+	}
+}
+
+// WithArgTypesOnly log เฉพาะชนิดของ arg เช่น string, int64 โดยไม่ log ค่าเลย
+func WithArgTypesOnly() TracingHookOption {
+	return func(h *TracingHook) {
+		h.redact.typesOnly = true
+	}
+}
+
+// apply แปลง args เป็น string ที่ผ่านการ redact แล้วตามลำดับเดิม
+func (p redactPolicy) apply(query string, args []interface{}) []string {
+	result := make([]string, len(args))
+	if p.typesOnly {
+		for i, arg := range args {
+			result[i] = fmt.Sprintf("%T", arg)
+		}
+		return result
+	}
+
+	var columns map[int]string
+	if len(p.maskedColumns) > 0 {
+		columns = argColumns(query)
+	}
+
+	for i, arg := range args {
+		if p.isMaskedColumn(columns[i]) {
+			result[i] = redactedValue
+			continue
+		}
+
+		value := cast.ToString(arg)
+		for _, pattern := range p.patterns {
+			value = pattern.ReplaceAllString(value, redactedValue)
+		}
+		if p.maxArgLength > 0 && utf8.RuneCountInString(value) > p.maxArgLength {
+			value = fmt.Sprintf("%s...(%d bytes)", truncateRunes(value, p.maxArgLength), len(value))
+		}
+		result[i] = value
+	}
+	return result
+}
+
+// truncateRunes ตัด s ให้เหลือ n ตัวอักษรโดยไม่ตัดกลาง UTF-8 เช่นข้อความภาษาไทย
+func truncateRunes(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+func (p redactPolicy) isMaskedColumn(column string) bool {
+	if column == "" {
+		return false
+	}
+	for _, masked := range p.maskedColumns {
+		if strings.Contains(column, strings.ToLower(masked)) {
+			return true
+		}
+	}
+	return false
+}
+
+type argPlaceholder struct {
+	pos int
+	arg int
+}
+
+// argColumns จับคู่ลำดับ arg กับชื่อ column จาก INSERT (...) VALUES (...) และ column = ? ใน SET/WHERE
+func argColumns(query string) map[int]string {
+	// แทน string literal ด้วยช่องว่างความยาวเท่าเดิมเพื่อไม่ให้ ? ใน literal ถูกนับและตำแหน่งไม่เลื่อน
+	clean := stringLiteralReg.ReplaceAllStringFunc(query, func(literal string) string {
+		return strings.Repeat(" ", len(literal))
+	})
+	placeholders := findPlaceholders(clean)
+	columns := map[int]string{}
+
+	for _, match := range columnValueReg.FindAllStringSubmatchIndex(clean, -1) {
+		for _, placeholder := range placeholders {
+			if placeholder.pos == match[4] {
+				columns[placeholder.arg] = normalizeColumnName(clean[match[2]:match[3]])
+			}
+		}
+	}
+
+	match := insertColumnsReg.FindStringSubmatchIndex(clean)
+	if match == nil {
+		return columns
+	}
+	names := strings.Split(clean[match[2]:match[3]], ",")
+
+	// ไล่ VALUES ทีละตัวอักษร item คือตำแหน่งใน tuple ปัจจุบัน ใช้ได้กับ insert หลายแถว
+	depth, item, next := 0, 0, 0
+	for i := match[1]; i < len(clean) && next < len(placeholders); i++ {
+		for next < len(placeholders) && placeholders[next].pos < i {
+			next++
+		}
+		if next < len(placeholders) && placeholders[next].pos == i && depth == 1 && item < len(names) {
+			columns[placeholders[next].arg] = normalizeColumnName(names[item])
+		}
+
+		switch clean[i] {
+		case '(':
+			depth++
+			if depth == 1 {
+				item = 0
+			}
+		case ')':
+			depth--
+		case ',':
+			if depth == 1 {
+				item++
+			}
+		}
+	}
+	return columns
+}
+
+// findPlaceholders คืนตำแหน่งของ placeholder และลำดับ arg ที่อ้างถึง รองรับ ?, $n และ @pn
+func findPlaceholders(query string) []argPlaceholder {
+	placeholders := []argPlaceholder{}
+	sequence := 0
+	for _, loc := range placeholderReg.FindAllStringIndex(query, -1) {
+		token := query[loc[0]:loc[1]]
+		arg := sequence
+		switch {
+		case strings.HasPrefix(token, "$"):
+			n, _ := strconv.Atoi(token[1:])
+			arg = n - 1
+		case strings.HasPrefix(token, "@p"):
+			n, _ := strconv.Atoi(token[2:])
+			arg = n - 1
+		default:
+			sequence++
+		}
+		placeholders = append(placeholders, argPlaceholder{pos: loc[0], arg: arg})
+	}
+	return placeholders
+}
+
+func normalizeColumnName(column string) string {
+	column = strings.TrimSpace(column)
+	if index := strings.LastIndex(column, "."); index >= 0 {
+		column = column[index+1:]
+	}
+	return strings.ToLower(strings.Trim(column, "`\"[]"))
+}
@@ -0,0 +1,269 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jmoiron/sqlx"
+)
+
+type ReplicaStrategy int
+
+const (
+	// RoundRobin กระจาย query ไปทุก replica ที่ปกติเท่าๆ กัน
+	RoundRobin ReplicaStrategy = iota
+	// LeastLatency เลือก replica ที่ ping เร็วที่สุดในรอบตรวจล่าสุด
+	LeastLatency
+)
+
+const (
+	DefaultReplicaCheckInterval = 5 * time.Second
+	DefaultMaxReplicaLag        = 10 * time.Second
+)
+
+// WithReplicaStrategy กำหนดวิธีเลือก replica ค่าเริ่มต้นคือ RoundRobin
+func WithReplicaStrategy(strategy ReplicaStrategy) ClientOption {
+	return func(o *clientOptions) {
+		o.replicaStrategy = strategy
+	}
+}
+
+// WithMaxReplicaLag นำ replica ที่ lag เกิน d ออกจากการใช้งาน (ตรวจได้เฉพาะ Postgres)
+func WithMaxReplicaLag(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.maxReplicaLag = d
+	}
+}
+
+// WithReplicaCheckInterval กำหนดรอบการตรวจสุขภาพและ lag ของ replica
+func WithReplicaCheckInterval(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.replicaCheckInterval = d
+	}
+}
+
+type primaryContextKey struct{}
+
+// WithPrimary บังคับให้ query ที่ใช้ ctx นี้อ่านจาก primary เช่นหลังเขียนข้อมูลแล้วต้องอ่านค่าใหม่ทันที
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+func isPrimaryForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryContextKey{}).(bool)
+	return forced
+}
+
+type replica struct {
+	db      *sqlx.DB
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+	latency atomic.Int64
+}
+
+type replicaSet struct {
+	replicas  []*replica
+	driver    Driver
+	strategy  ReplicaStrategy
+	maxLag    time.Duration
+	interval  time.Duration
+	next      atomic.Uint64
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewReplicatedConnection เชื่อมต่อ primary และ read replica
+// Select/Get/Query ของ Client จะอ่านจาก replica ที่ปกติ ส่วนการเขียนและ transaction ใช้ primary เสมอ
+func NewReplicatedConnection(primaryStr string, replicaStrs []string, databaseType Driver, opts ...ClientOption) (*Client, error) {
+	ctx := context.Background()
+	client, err := connect(ctx, primaryStr, databaseType, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &clientOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	hooks := buildHooks(options, databaseType)
+
+	set := &replicaSet{
+		driver:   databaseType,
+		strategy: options.replicaStrategy,
+		maxLag:   options.maxReplicaLag,
+		interval: options.replicaCheckInterval,
+		stopChan: make(chan struct{}),
+	}
+	if set.maxLag <= 0 {
+		set.maxLag = DefaultMaxReplicaLag
+	}
+	if set.interval <= 0 {
+		set.interval = DefaultReplicaCheckInterval
+	}
+
+	for _, replicaStr := range replicaStrs {
+		db, pool, err := connectOnce(ctx, replicaStr, databaseType, options, hooks)
+		if err != nil {
+			set.close()
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to replica: %v", err)
+		}
+		set.replicas = append(set.replicas, &replica{db: db, pool: pool})
+	}
+
+	set.check(ctx)
+	set.wg.Add(1)
+	go set.checkLoop()
+
+	client.replicas = set
+	return client, nil
+}
+
+func (s *replicaSet) checkLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.check(context.Background())
+		}
+	}
+}
+
+// check ping ทุก replica และตรวจ replication lag แล้วปรับสถานะ healthy
+func (s *replicaSet) check(ctx context.Context) {
+	for _, r := range s.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, s.interval)
+		start := time.Now()
+		err := r.db.PingContext(checkCtx)
+		r.latency.Store(int64(time.Since(start)))
+
+		if err == nil && s.driver == Postgres {
+			err = checkReplicaLag(checkCtx, r.db, s.maxLag)
+		}
+		cancel()
+
+		wasHealthy := r.healthy.Swap(err == nil)
+		if err != nil && wasHealthy {
+			log.Printf("Warning: replica removed from rotation: %v", err)
+		} else if err == nil && !wasHealthy {
+			log.Printf("Replica returned to rotation")
+		}
+	}
+}
+
+// replicaLagQuery ใช้ lag 0 เมื่อ replay ทัน WAL ที่รับมาแล้ว (primary ไม่มี transaction ใหม่มานาน)
+// เฉพาะตอนที่ WAL receiver ยัง streaming อยู่ ถ้าหลุดจาก primary ค่า LSN จะเท่ากันเสมอแต่ข้อมูลเก่าลงเรื่อย ๆ
+// การอ่าน status ของ pg_stat_wal_receiver ต้องใช้ role ที่มีสิทธิ์ pg_read_all_stats
+const replicaLagQuery = `SELECT
+	EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming') AS streaming,
+	CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	END AS lag_seconds`
+
+// checkReplicaLag คืน error ถ้า replica ไม่ได้ streaming จาก primary หรือ lag เกิน maxLag
+func checkReplicaLag(ctx context.Context, db *sqlx.DB, maxLag time.Duration) error {
+	var result struct {
+		Streaming  bool            `db:"streaming"`
+		LagSeconds sql.NullFloat64 `db:"lag_seconds"`
+	}
+	if err := db.GetContext(ctx, &result, replicaLagQuery); err != nil {
+		return err
+	}
+	if !result.Streaming {
+		return fmt.Errorf("replica is not streaming from primary")
+	}
+	if result.LagSeconds.Valid && time.Duration(result.LagSeconds.Float64*float64(time.Second)) > maxLag {
+		return fmt.Errorf("replication lag %.1fs exceeds %v", result.LagSeconds.Float64, maxLag)
+	}
+	return nil
+}
+
+// pick เลือก replica ที่ปกติตาม strategy คืน nil ถ้าไม่มี replica ที่ใช้ได้
+func (s *replicaSet) pick() *sqlx.DB {
+	healthy := make([]*replica, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	if s.strategy == LeastLatency {
+		best := healthy[0]
+		for _, r := range healthy[1:] {
+			if r.latency.Load() < best.latency.Load() {
+				best = r
+			}
+		}
+		return best.db
+	}
+	return healthy[s.next.Add(1)%uint64(len(healthy))].db
+}
+
+func (s *replicaSet) close() error {
+	var closeErr error
+	s.closeOnce.Do(func() {
+		close(s.stopChan)
+		s.wg.Wait()
+		for _, r := range s.replicas {
+			if err := r.db.Close(); err != nil {
+				closeErr = err
+			}
+			if r.pool != nil {
+				r.pool.Close()
+			}
+		}
+	})
+	return closeErr
+}
+
+// Reader คืน executor สำหรับอ่าน: transaction ใน ctx, primary ถ้าถูกบังคับด้วย WithPrimary
+// หรือไม่มี replica ที่ปกติ ไม่เช่นนั้นคืน replica
+func (c *Client) Reader(ctx context.Context) Executor {
+	if tx, ok := c.TxFromContext(ctx); ok {
+		return tx
+	}
+	if c.replicas == nil || isPrimaryForced(ctx) {
+		return c.db
+	}
+	if db := c.replicas.pick(); db != nil {
+		return db
+	}
+	return c.db
+}
+
+// Select อ่านหลายแถวจาก replica ผ่าน Reader
+func (c *Client) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return c.Reader(ctx).SelectContext(ctx, dest, query, args...)
+}
+
+// Get อ่านหนึ่งแถวจาก replica ผ่าน Reader
+func (c *Client) Get(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return c.Reader(ctx).GetContext(ctx, dest, query, args...)
+}
+
+// Query รัน query จาก replica ผ่าน Reader
+func (c *Client) Query(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return c.Reader(ctx).QueryxContext(ctx, query, args...)
+}
+
+// Exec รันคำสั่งเขียนบน primary หรือ transaction ใน ctx
+func (c *Client) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.Executor(ctx).ExecContext(ctx, query, args...)
+}
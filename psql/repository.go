@@ -0,0 +1,444 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/GodeFvt/go-backend/helper/models"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+var (
+	zeroUUIDType  = reflect.TypeOf(models.ZeroUUID{})
+	timestampType = reflect.TypeOf(models.Timestamp{})
+	timeType      = reflect.TypeOf(time.Time{})
+	modelsPkgPath = zeroUUIDType.PkgPath()
+)
+
+type repositoryOptions struct {
+	primaryKey      string
+	createdAtColumn string
+	updatedAtColumn string
+}
+
+// RepositoryOption ปรับ column พิเศษของ NewRepository
+type RepositoryOption func(*repositoryOptions)
+
+// WithPrimaryKey กำหนด column ที่เป็น primary key ค่าเริ่มต้นคือ "id"
+func WithPrimaryKey(column string) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.primaryKey = column
+	}
+}
+
+// WithAuditColumns กำหนด column เวลาสร้างและเวลาแก้ไข ค่าเริ่มต้นคือ "created_at" และ "updated_at"
+// ส่งค่าว่างเพื่อปิด column นั้น
+func WithAuditColumns(createdAt, updatedAt string) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.createdAtColumn = createdAt
+		o.updatedAtColumn = updatedAt
+	}
+}
+
+type repositoryColumn struct {
+	name  string
+	index []int
+}
+
+// Repository สร้าง SQL สำหรับ CRUD ของ T จาก db tag ตาม dialect ของ Client
+// primary key ชนิด models.ZeroUUID ที่เป็นค่าว่างจะถูกสร้างให้ตอน Insert
+// ส่วน primary key ชนิดตัวเลขที่เป็น 0 จะให้ฐานข้อมูลสร้างแล้วอ่านกลับมาใส่ entity
+// column created_at/updated_at ชนิด models.Timestamp หรือ time.Time (หรือ pointer ของทั้งสอง) จะถูกตั้งค่าอัตโนมัติ
+// ถ้าเป็นชนิดอื่นจะไม่ถูกตั้งค่าและ UpdateColumns จะไม่เพิ่ม updated_at ให้เอง
+// การเขียนใช้ Client.Executor(ctx) จึงทำงานใน transaction ของ WithTx ได้ ส่วนการอ่านใช้ Client.Reader(ctx)
+type Repository[T any] struct {
+	client     *Client
+	driver     Driver
+	table      string
+	primaryKey repositoryColumn
+	createdAt  *repositoryColumn
+	updatedAt  *repositoryColumn
+	columns    []repositoryColumn
+}
+
+// NewRepository สร้าง Repository ของตาราง table โดยอ่าน column จาก db tag ของ T
+func NewRepository[T any](client *Client, table string, opts ...RepositoryOption) (*Repository[T], error) {
+	options := &repositoryOptions{
+		primaryKey:      "id",
+		createdAtColumn: "created_at",
+		updatedAtColumn: "updated_at",
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("repository requires a struct type, got %s", t)
+	}
+
+	repo := &Repository[T]{
+		client: client,
+		driver: Driver(client.driverName),
+		table:  table,
+	}
+
+	// ใช้ mapper ของ sqlx เพื่อให้ชื่อ column ตรงกับตอน scan และรองรับ struct ที่ embed
+	var hasPrimaryKey bool
+	for _, field := range client.db.Mapper.TypeMap(t).Index {
+		if field.Embedded || strings.Contains(field.Path, ".") {
+			continue
+		}
+		column := repositoryColumn{name: field.Name, index: field.Index}
+		switch field.Name {
+		case options.primaryKey:
+			repo.primaryKey = column
+			hasPrimaryKey = true
+		case options.createdAtColumn:
+			repo.createdAt = &column
+		case options.updatedAtColumn:
+			repo.updatedAt = &column
+		}
+		repo.columns = append(repo.columns, column)
+	}
+	if !hasPrimaryKey {
+		return nil, fmt.Errorf("primary key column %s not found in %s", options.primaryKey, t)
+	}
+
+	return repo, nil
+}
+
+// quoteIdentifier ครอบชื่อ table หรือ column ตาม dialect รองรับชื่อแบบ schema.table
+func quoteIdentifier(databaseType Driver, name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		switch databaseType {
+		case Postgres:
+			parts[i] = `"` + part + `"`
+		case Mssql:
+			parts[i] = "[" + part + "]"
+		default:
+			parts[i] = "`" + part + "`"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (r *Repository[T]) quote(name string) string {
+	return quoteIdentifier(r.driver, name)
+}
+
+func (r *Repository[T]) selectColumns() string {
+	names := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		names[i] = r.quote(column.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// value คืนค่าของ column ที่พร้อมส่งให้ driver
+// JsonScan ถูกแปลงเป็น string สำหรับ driver ที่ไม่รับ []byte เป็นข้อความ
+func (r *Repository[T]) value(entity reflect.Value, column repositoryColumn) (interface{}, error) {
+	field := reflectx.FieldByIndexesReadOnly(entity, column.index)
+	if !isJsonScan(field.Type()) {
+		return field.Interface(), nil
+	}
+
+	value, err := field.Interface().(driver.Valuer).Value()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode column %s: %v", column.name, err)
+	}
+	if data, ok := value.([]byte); ok && r.driver != Postgres {
+		return string(data), nil
+	}
+	return value, nil
+}
+
+func isJsonScan(t reflect.Type) bool {
+	return t.PkgPath() == modelsPkgPath && strings.HasPrefix(t.Name(), "JsonScan[")
+}
+
+// isAutoIncrement ตรวจว่า primary key เป็นตัวเลขที่ยังเป็น 0 ซึ่งให้ฐานข้อมูลสร้างค่า
+func (r *Repository[T]) isAutoIncrement(entity reflect.Value) bool {
+	field := reflectx.FieldByIndexesReadOnly(entity, r.primaryKey.index)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field.IsZero()
+	}
+	return false
+}
+
+// setTimestamp ตั้งเวลาให้ audit column คืน false ถ้า column ไม่มีหรือเป็นชนิดที่ตั้งเวลาให้ไม่ได้
+// รองรับ models.Timestamp, time.Time และ pointer ของทั้งสองชนิด
+func setTimestamp(entity reflect.Value, column *repositoryColumn, now time.Time, onlyIfZero bool) bool {
+	if column == nil {
+		return false
+	}
+	field := reflectx.FieldByIndexes(entity, column.index)
+
+	var value reflect.Value
+	switch field.Type() {
+	case timestampType:
+		value = reflect.ValueOf(models.NewTimestampFromTime(now))
+	case timeType:
+		value = reflect.ValueOf(now)
+	case reflect.PtrTo(timestampType):
+		ts := models.NewTimestampFromTime(now)
+		value = reflect.ValueOf(&ts)
+	case reflect.PtrTo(timeType):
+		t := now
+		value = reflect.ValueOf(&t)
+	default:
+		return false
+	}
+
+	if onlyIfZero && !(field.IsZero() || (field.Kind() == reflect.Ptr && field.Elem().IsZero())) {
+		return true
+	}
+	field.Set(value)
+	return true
+}
+
+// Insert เพิ่ม entity และตั้งค่า primary key และ audit column กลับเข้า entity
+func (r *Repository[T]) Insert(ctx context.Context, entity *T) error {
+	v := reflect.ValueOf(entity).Elem()
+
+	pk := reflectx.FieldByIndexes(v, r.primaryKey.index)
+	if pk.Type() == zeroUUIDType && pk.Interface().(models.ZeroUUID).IsZero() {
+		pk.Set(reflect.ValueOf(models.NewV4()))
+	}
+	now := time.Now()
+	setTimestamp(v, r.createdAt, now, true)
+	setTimestamp(v, r.updatedAt, now, true)
+
+	autoIncrement := r.isAutoIncrement(v)
+	names := []string{}
+	placeholders := []string{}
+	args := []interface{}{}
+	for _, column := range r.columns {
+		if autoIncrement && column.name == r.primaryKey.name {
+			continue
+		}
+		value, err := r.value(v, column)
+		if err != nil {
+			return err
+		}
+		names = append(names, r.quote(column.name))
+		placeholders = append(placeholders, "?")
+		args = append(args, value)
+	}
+
+	db := r.client.Executor(ctx)
+	columns, values := strings.Join(names, ", "), strings.Join(placeholders, ", ")
+	if !autoIncrement {
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.quote(r.table), columns, values)
+		if _, err := db.ExecContext(ctx, db.Rebind(query), args...); err != nil {
+			return fmt.Errorf("failed to insert into %s: %v", r.table, err)
+		}
+		return nil
+	}
+
+	// อ่าน primary key ที่ฐานข้อมูลสร้างกลับมาตามวิธีของแต่ละ dialect
+	switch r.driver {
+	case Postgres:
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s", r.quote(r.table), columns, values, r.quote(r.primaryKey.name))
+		if err := db.QueryRowxContext(ctx, db.Rebind(query), args...).Scan(pk.Addr().Interface()); err != nil {
+			return fmt.Errorf("failed to insert into %s: %v", r.table, err)
+		}
+	case Mssql:
+		query := fmt.Sprintf("INSERT INTO %s (%s) OUTPUT INSERTED.%s VALUES (%s)", r.quote(r.table), columns, r.quote(r.primaryKey.name), values)
+		if err := db.QueryRowxContext(ctx, db.Rebind(query), args...).Scan(pk.Addr().Interface()); err != nil {
+			return fmt.Errorf("failed to insert into %s: %v", r.table, err)
+		}
+	case MySQL:
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.quote(r.table), columns, values)
+		result, err := db.ExecContext(ctx, db.Rebind(query), args...)
+		if err != nil {
+			return fmt.Errorf("failed to insert into %s: %v", r.table, err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get inserted id: %v", err)
+		}
+		if pk.CanInt() {
+			pk.SetInt(id)
+		} else {
+			pk.SetUint(uint64(id))
+		}
+	default:
+		return fmt.Errorf("%s does not generate primary keys, set %s before insert", r.driver, r.primaryKey.name)
+	}
+	return nil
+}
+
+// GetByID คืน entity ตาม primary key หรือ sql.ErrNoRows ถ้าไม่พบ
+func (r *Repository[T]) GetByID(ctx context.Context, id interface{}) (*T, error) {
+	db := r.client.Reader(ctx)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", r.selectColumns(), r.quote(r.table), r.quote(r.primaryKey.name))
+
+	entity := new(T)
+	if err := db.GetContext(ctx, entity, db.Rebind(query), id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get %s: %v", r.table, err)
+	}
+	return entity, nil
+}
+
+// List คืน entity ที่ตรงเงื่อนไข where (ใช้ ? เป็น placeholder ส่งค่าว่างเพื่อเอาทั้งหมด)
+// ถ้า page ไม่เป็น nil จะแบ่งหน้าด้วย Paginate เรียงตาม primary key
+func (r *Repository[T]) List(ctx context.Context, page *models.Paginator, where string, args ...interface{}) ([]T, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", r.selectColumns(), r.quote(r.table))
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY " + r.quote(r.primaryKey.name)
+
+	if page != nil {
+		return Paginate[T](ctx, r.client, query, args, page)
+	}
+
+	db := r.client.Reader(ctx)
+	items := []T{}
+	if err := db.SelectContext(ctx, &items, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", r.table, err)
+	}
+	return items, nil
+}
+
+// Update แก้ทุก column ยกเว้น primary key และ created_at
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	columns := []string{}
+	for _, column := range r.columns {
+		if column.name != r.primaryKey.name && (r.createdAt == nil || column.name != r.createdAt.name) {
+			columns = append(columns, column.name)
+		}
+	}
+	return r.UpdateColumns(ctx, entity, columns...)
+}
+
+// UpdateChanged เทียบ entity กับ original แล้วแก้เฉพาะ column ที่เปลี่ยน คืนรายชื่อ column ที่ถูกแก้
+// ถ้าไม่มีอะไรเปลี่ยนจะไม่ส่ง query
+func (r *Repository[T]) UpdateChanged(ctx context.Context, original, entity *T) ([]string, error) {
+	before, after := reflect.ValueOf(original).Elem(), reflect.ValueOf(entity).Elem()
+
+	changed := []string{}
+	for _, column := range r.columns {
+		if column.name == r.primaryKey.name || (r.updatedAt != nil && column.name == r.updatedAt.name) {
+			continue
+		}
+		oldValue, err := comparableValue(before, column)
+		if err != nil {
+			return nil, err
+		}
+		newValue, err := comparableValue(after, column)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changed = append(changed, column.name)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	if err := r.UpdateColumns(ctx, entity, changed...); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// comparableValue ใช้ค่าจาก driver.Valuer ถ้ามีเพื่อให้ JsonScan ที่เก็บ pointer เทียบด้วยเนื้อหา
+func comparableValue(entity reflect.Value, column repositoryColumn) (interface{}, error) {
+	value := reflectx.FieldByIndexesReadOnly(entity, column.index).Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode column %s: %v", column.name, err)
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// UpdateColumns แก้เฉพาะ columns ที่ระบุของ entity ตาม primary key และตั้ง updated_at ให้อัตโนมัติ
+func (r *Repository[T]) UpdateColumns(ctx context.Context, entity *T, columns ...string) error {
+	v := reflect.ValueOf(entity).Elem()
+	// updated_at ชนิดที่ตั้งเวลาให้ไม่ได้จะไม่ถูกเพิ่มเข้าไปเอง เพื่อไม่ให้เขียนค่าเดิมทับ
+	if setTimestamp(v, r.updatedAt, time.Now(), false) && !containsColumn(columns, r.updatedAt.name) {
+		columns = append(columns, r.updatedAt.name)
+	}
+
+	sets := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns)+1)
+	for _, name := range columns {
+		column, ok := r.column(name)
+		if !ok {
+			return fmt.Errorf("column %s not found in %s", name, r.table)
+		}
+		value, err := r.value(v, column)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, r.quote(column.name)+" = ?")
+		args = append(args, value)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	args = append(args, reflectx.FieldByIndexesReadOnly(v, r.primaryKey.index).Interface())
+
+	// ClickHouse แก้ข้อมูลผ่าน mutation ของ ALTER TABLE
+	format := "UPDATE %s SET %s WHERE %s = ?"
+	if r.driver == Clickhouse {
+		format = "ALTER TABLE %s UPDATE %s WHERE %s = ?"
+	}
+	query := fmt.Sprintf(format, r.quote(r.table), strings.Join(sets, ", "), r.quote(r.primaryKey.name))
+
+	db := r.client.Executor(ctx)
+	if _, err := db.ExecContext(ctx, db.Rebind(query), args...); err != nil {
+		return fmt.Errorf("failed to update %s: %v", r.table, err)
+	}
+	return nil
+}
+
+// Delete ลบแถวตาม primary key
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	format := "DELETE FROM %s WHERE %s = ?"
+	if r.driver == Clickhouse {
+		format = "ALTER TABLE %s DELETE WHERE %s = ?"
+	}
+	query := fmt.Sprintf(format, r.quote(r.table), r.quote(r.primaryKey.name))
+
+	db := r.client.Executor(ctx)
+	if _, err := db.ExecContext(ctx, db.Rebind(query), id); err != nil {
+		return fmt.Errorf("failed to delete from %s: %v", r.table, err)
+	}
+	return nil
+}
+
+func (r *Repository[T]) column(name string) (repositoryColumn, bool) {
+	for _, column := range r.columns {
+		if column.name == name {
+			return column, true
+		}
+	}
+	return repositoryColumn{}, false
+}
+
+func containsColumn(columns []string, name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,347 @@
+package psql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultSlowQueryThreshold = 500 * time.Millisecond
+	DefaultMaxStatements      = 1000
+	DefaultStatsSampleSize    = 512
+)
+
+var (
+	stringLiteralReg = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberLiteralReg = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	placeholderReg   = regexp.MustCompile(`\$\d+|@p\d+|\?`)
+	valueListReg     = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+	whitespaceReg    = regexp.MustCompile(`\s+`)
+)
+
+type QueryStatsConfig struct {
+	// SlowThreshold query ที่ใช้เวลานานกว่านี้จะถูก log
+	SlowThreshold time.Duration
+	// MaxStatements จำนวน statement สูงสุดที่เก็บสถิติ statement ใหม่หลังจากนั้นจะไม่ถูกนับ
+	MaxStatements int
+	// SampleSize จำนวนเวลาล่าสุดต่อ statement ที่ใช้คำนวณ p50/p95
+	SampleSize int
+	// StatementLabel เพิ่ม label statement ที่เป็นข้อความ SQL เต็มให้ metric ของ Prometheus
+	// ปกติ metric มีแค่ label statement_hash เพื่อไม่ให้ label ยาวและมีจำนวนค่ามากเกินไป
+	// ดูข้อความของแต่ละ hash ได้จาก Stats หรือ Handler
+	StatementLabel bool
+}
+
+type QueryStat struct {
+	Statement string        `json:"statement"`
+	Hash      string        `json:"hash"`
+	Count     int64         `json:"count"`
+	Errors    int64         `json:"errors"`
+	Rows      int64         `json:"rows"`
+	Total     time.Duration `json:"total"`
+	P50       time.Duration `json:"p50"`
+	P95       time.Duration `json:"p95"`
+	Max       time.Duration `json:"max"`
+}
+
+type statementStats struct {
+	count   int64
+	errors  int64
+	rows    int64
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration
+	next    int
+}
+
+func (s *statementStats) observe(elapsed time.Duration, rows int64, failed bool, sampleSize int) {
+	s.count++
+	s.total += elapsed
+	if rows > 0 {
+		s.rows += rows
+	}
+	if failed {
+		s.errors++
+	}
+	if elapsed > s.max {
+		s.max = elapsed
+	}
+	if len(s.samples) < sampleSize {
+		s.samples = append(s.samples, elapsed)
+		return
+	}
+	s.samples[s.next] = elapsed
+	s.next = (s.next + 1) % sampleSize
+}
+
+func (s *statementStats) snapshot(statement string) QueryStat {
+	sorted := append([]time.Duration{}, s.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return QueryStat{
+		Statement: statement,
+		Hash:      statementHash(statement),
+		Count:     s.count,
+		Errors:    s.errors,
+		Rows:      s.rows,
+		Total:     s.total,
+		P50:       percentile(sorted, 0.5),
+		P95:       percentile(sorted, 0.95),
+		Max:       s.max,
+	}
+}
+
+// statementHash คืน hash สั้น ๆ ของ statement ใช้เป็น label ของ Prometheus แทนข้อความเต็ม
+func statementHash(statement string) string {
+	sum := sha256.Sum256([]byte(statement))
+	return hex.EncodeToString(sum[:8])
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index]
+}
+
+type queryObservationKey struct{}
+
+// queryObservation เก็บข้อมูลของ query หนึ่งครั้งระหว่าง Before กับ After
+// countingConn ที่อยู่ใต้ sqlhooks ใส่จำนวนแถวให้ (RowsAffected ของ exec หรือจำนวนแถวที่อ่านของ query)
+// query ที่คืน rows จะถูกบันทึกตอน rows ถูกปิด เพื่อให้นับแถวได้ครบ
+type queryObservation struct {
+	hook     *QueryStatsHook
+	query    string
+	args     []interface{}
+	start    time.Time
+	rows     int64
+	deferred bool
+	once     sync.Once
+}
+
+func (o *queryObservation) finish(rows int64) {
+	o.rows = rows
+	o.record(nil)
+}
+
+func (o *queryObservation) record(err error) {
+	o.once.Do(func() {
+		o.hook.record(o.query, o.args, time.Since(o.start), o.rows, err)
+	})
+}
+
+// QueryStatsHook เป็น sqlhooks ที่ log query ช้าและเก็บสถิติต่อ statement ที่ normalize แล้ว
+// ใช้ร่วมกับ TracingHook ได้ผ่าน WithHooks
+// จำนวนแถวคือ RowsAffected ของคำสั่งเขียน หรือจำนวนแถวที่อ่านจริงของ query และเวลาของ query
+// นับจนถึงตอนที่ rows ถูกปิด ใช้ได้เฉพาะ connection ที่สร้างผ่าน NewConnection ของ package นี้
+//
+//	stats := psql.NewQueryStatsHook(psql.QueryStatsConfig{SlowThreshold: time.Second})
+//	client, err := psql.NewConnection(dsn, psql.Postgres, psql.WithHooks(stats))
+//	e.GET("/debug/sql", stats.Handler())
+//	prometheus.MustRegister(stats)
+type QueryStatsHook struct {
+	config QueryStatsConfig
+	mu     sync.Mutex
+	stats  map[string]*statementStats
+
+	durationDesc *prometheus.Desc
+	errorsDesc   *prometheus.Desc
+	maxDesc      *prometheus.Desc
+}
+
+func NewQueryStatsHook(config QueryStatsConfig) *QueryStatsHook {
+	if config.SlowThreshold <= 0 {
+		config.SlowThreshold = DefaultSlowQueryThreshold
+	}
+	if config.MaxStatements <= 0 {
+		config.MaxStatements = DefaultMaxStatements
+	}
+	if config.SampleSize <= 0 {
+		config.SampleSize = DefaultStatsSampleSize
+	}
+
+	labels := []string{"statement_hash"}
+	if config.StatementLabel {
+		labels = append(labels, "statement")
+	}
+
+	return &QueryStatsHook{
+		config: config,
+		stats:  make(map[string]*statementStats),
+		durationDesc: prometheus.NewDesc("sql_query_duration_seconds",
+			"Latency of SQL statements", labels, nil),
+		errorsDesc: prometheus.NewDesc("sql_query_errors_total",
+			"Number of failed SQL statements", labels, nil),
+		maxDesc: prometheus.NewDesc("sql_query_max_duration_seconds",
+			"Slowest execution of SQL statements", labels, nil),
+	}
+}
+
+// NormalizeQuery แทนค่า literal และ placeholder ด้วย ? และยุบ list ของค่าให้เหลือ (?)
+// เพื่อให้ query เดียวกันที่ต่างกันแค่ค่ารวมเป็น statement เดียว
+func NormalizeQuery(query string) string {
+	query = stringLiteralReg.ReplaceAllString(query, "?")
+	query = placeholderReg.ReplaceAllString(query, "?")
+	query = numberLiteralReg.ReplaceAllString(query, "?")
+	query = valueListReg.ReplaceAllString(query, "(?)")
+	query = whitespaceReg.ReplaceAllString(query, " ")
+	return strings.TrimSpace(query)
+}
+
+func (h *QueryStatsHook) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	observation := &queryObservation{hook: h, query: query, args: args, start: time.Now(), rows: -1}
+	return context.WithValue(ctx, queryObservationKey{}, observation), nil
+}
+
+func (h *QueryStatsHook) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if observation, ok := h.observation(ctx); ok && !observation.deferred {
+		observation.record(nil)
+	}
+	return ctx, nil
+}
+
+func (h *QueryStatsHook) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	if observation, ok := h.observation(ctx); ok {
+		observation.record(err)
+	}
+	return err
+}
+
+func (h *QueryStatsHook) observation(ctx context.Context) (*queryObservation, bool) {
+	observation, ok := ctx.Value(queryObservationKey{}).(*queryObservation)
+	if !ok || observation.hook != h {
+		return nil, false
+	}
+	return observation, true
+}
+
+// record บันทึกสถิติของ query หนึ่งครั้ง rows เป็น -1 เมื่อ driver ไม่บอกจำนวนแถว
+func (h *QueryStatsHook) record(query string, args []interface{}, elapsed time.Duration, rows int64, err error) {
+	statement := NormalizeQuery(query)
+
+	if elapsed >= h.config.SlowThreshold {
+		log.Printf("Slow query (%v) at %s: %s [rows: %s, args: %d, error: %v]", elapsed, queryCaller(), statement, formatRows(rows), len(args), err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats, exists := h.stats[statement]
+	if !exists {
+		if len(h.stats) >= h.config.MaxStatements {
+			return
+		}
+		stats = &statementStats{}
+		h.stats[statement] = stats
+	}
+	stats.observe(elapsed, rows, err != nil, h.config.SampleSize)
+}
+
+func formatRows(rows int64) string {
+	if rows < 0 {
+		return "unknown"
+	}
+	return strconv.FormatInt(rows, 10)
+}
+
+// queryCaller หา file:line แรกที่อยู่นอก database/sql, driver และ package นี้
+func queryCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isLibraryFrame(frame.Function) {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func isLibraryFrame(function string) bool {
+	for _, prefix := range []string{
+		"database/sql.",
+		"github.com/jmoiron/sqlx",
+		"github.com/qustavo/sqlhooks",
+		"github.com/jackc/pgx",
+		"github.com/go-sql-driver/mysql",
+		"github.com/denisenkom/go-mssqldb",
+		"github.com/ClickHouse/clickhouse-go",
+		"github.com/GodeFvt/go-backend/psql.",
+		"runtime.",
+	} {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats คืนสถิติของทุก statement เรียงตามเวลารวมจากมากไปน้อย
+func (h *QueryStatsHook) Stats() []QueryStat {
+	h.mu.Lock()
+	result := make([]QueryStat, 0, len(h.stats))
+	for statement, stats := range h.stats {
+		result = append(result, stats.snapshot(statement))
+	}
+	h.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Total > result[j].Total })
+	return result
+}
+
+// Reset ล้างสถิติทั้งหมด
+func (h *QueryStatsHook) Reset() {
+	h.mu.Lock()
+	h.stats = make(map[string]*statementStats)
+	h.mu.Unlock()
+}
+
+// Handler คืน echo handler สำหรับ debug endpoint ที่แสดงสถิติเป็น JSON
+// ส่ง ?reset=true เพื่อล้างสถิติหลังอ่าน
+func (h *QueryStatsHook) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		stats := h.Stats()
+		if c.QueryParam("reset") == "true" {
+			h.Reset()
+		}
+		return c.JSON(http.StatusOK, stats)
+	}
+}
+
+// Describe implements prometheus.Collector
+func (h *QueryStatsHook) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.durationDesc
+	ch <- h.errorsDesc
+	ch <- h.maxDesc
+}
+
+// Collect implements prometheus.Collector
+func (h *QueryStatsHook) Collect(ch chan<- prometheus.Metric) {
+	for _, stat := range h.Stats() {
+		labels := []string{stat.Hash}
+		if h.config.StatementLabel {
+			labels = append(labels, stat.Statement)
+		}
+		ch <- prometheus.MustNewConstSummary(h.durationDesc, uint64(stat.Count), stat.Total.Seconds(),
+			map[float64]float64{0.5: stat.P50.Seconds(), 0.95: stat.P95.Seconds()}, labels...)
+		ch <- prometheus.MustNewConstMetric(h.errorsDesc, prometheus.CounterValue, float64(stat.Errors), labels...)
+		ch <- prometheus.MustNewConstMetric(h.maxDesc, prometheus.GaugeValue, stat.Max.Seconds(), labels...)
+	}
+}
@@ -0,0 +1,191 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultTxMaxRetries   = 3
+	DefaultTxRetryBackoff = 50 * time.Millisecond
+)
+
+// TxOptions ใช้กับ WithTx ถ้าส่ง nil จะใช้ isolation level เริ่มต้นของฐานข้อมูลและ retry DefaultTxMaxRetries ครั้ง
+type TxOptions struct {
+	sql.TxOptions
+	// MaxRetries จำนวนครั้งที่ลองใหม่เมื่อเจอ serialization failure หรือ deadlock
+	MaxRetries int
+	// RetryBackoff เวลารอก่อนลองใหม่ครั้งแรก และเพิ่มเป็นสองเท่าทุกครั้ง
+	RetryBackoff time.Duration
+}
+
+// TxFunc คือ function ที่ทำงานภายใน transaction ctx ที่ได้รับมี transaction ติดอยู่
+// repository ที่เรียก Client.Executor(ctx) จะได้ transaction เดียวกัน
+type TxFunc func(ctx context.Context, tx *sqlx.Tx) error
+
+// Executor คือ interface ร่วมของ *sqlx.DB และ *sqlx.Tx
+type Executor interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+type txContextKey struct{}
+
+type txState struct {
+	client *Client
+	tx     *sqlx.Tx
+	depth  int
+}
+
+func txStateFromContext(ctx context.Context, client *Client) *txState {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok || state.client != client {
+		return nil
+	}
+	return state
+}
+
+// TxFromContext คืน transaction ที่กำลังทำงานอยู่ใน ctx ของ client นี้
+func (c *Client) TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	state := txStateFromContext(ctx, c)
+	if state == nil {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// Executor คืน transaction ใน ctx ถ้ามี ไม่เช่นนั้นคืน *sqlx.DB
+func (c *Client) Executor(ctx context.Context) Executor {
+	if tx, ok := c.TxFromContext(ctx); ok {
+		return tx
+	}
+	return c.db
+}
+
+// WithTx รัน fn ภายใน transaction แล้ว commit ถ้า fn คืน nil และ rollback ถ้า fn คืน error หรือ panic
+// ถ้า ctx มี transaction อยู่แล้วจะใช้ savepoint แทนการเปิด transaction ใหม่
+// transaction ชั้นนอกสุดจะถูกลองใหม่เมื่อเจอ serialization failure หรือ deadlock
+func (c *Client) WithTx(ctx context.Context, opts *TxOptions, fn TxFunc) error {
+	if state := txStateFromContext(ctx, c); state != nil {
+		return c.withSavepoint(ctx, state, fn)
+	}
+
+	if opts == nil {
+		opts = &TxOptions{MaxRetries: DefaultTxMaxRetries}
+	}
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultTxRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.runTx(ctx, &opts.TxOptions, fn)
+		if err == nil || attempt >= opts.MaxRetries || !IsRetryableTxError(err) {
+			return err
+		}
+
+		log.Printf("Transaction failed, retrying in %v (%d/%d): %v", backoff, attempt+1, opts.MaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) runTx(ctx context.Context, opts *sql.TxOptions, fn TxFunc) (err error) {
+	tx, err := c.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+				log.Printf("Warning: failed to rollback transaction: %v", rollbackErr)
+			}
+		}
+	}()
+
+	txCtx := context.WithValue(ctx, txContextKey{}, &txState{client: c, tx: tx})
+	if err = fn(txCtx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *Client) withSavepoint(ctx context.Context, state *txState, fn TxFunc) (err error) {
+	nested := &txState{client: c, tx: state.tx, depth: state.depth + 1}
+	nestedCtx := context.WithValue(ctx, txContextKey{}, nested)
+
+	// ClickHouse ไม่รองรับ savepoint จึงรันใน transaction เดิมตรงๆ
+	if Driver(c.driverName) == Clickhouse {
+		return fn(nestedCtx, state.tx)
+	}
+
+	name := fmt.Sprintf("sp_%d", nested.depth)
+	savepoint, rollback, release := "SAVEPOINT "+name, "ROLLBACK TO SAVEPOINT "+name, "RELEASE SAVEPOINT "+name
+	if Driver(c.driverName) == Mssql {
+		savepoint, rollback, release = "SAVE TRANSACTION "+name, "ROLLBACK TRANSACTION "+name, ""
+	}
+
+	if _, err := state.tx.ExecContext(ctx, savepoint); err != nil {
+		return fmt.Errorf("failed to create savepoint: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			state.tx.ExecContext(ctx, rollback)
+			panic(p)
+		}
+		if err != nil {
+			if _, rollbackErr := state.tx.ExecContext(ctx, rollback); rollbackErr != nil {
+				log.Printf("Warning: failed to rollback savepoint %s: %v", name, rollbackErr)
+			}
+		}
+	}()
+
+	if err = fn(nestedCtx, state.tx); err != nil {
+		return err
+	}
+	if release != "" {
+		if _, err = state.tx.ExecContext(ctx, release); err != nil {
+			return fmt.Errorf("failed to release savepoint: %v", err)
+		}
+	}
+	return nil
+}
+
+// IsRetryableTxError ตรวจว่า error เป็น serialization failure หรือ deadlock ที่ลองใหม่ได้
+// Postgres SQLSTATE 40001/40P01, MySQL 1213 และ MSSQL 1205
+func IsRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return mssqlErr.Number == 1205
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package redis
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"reflect"
+	"time"
+
+	rdb "github.com/redis/go-redis/v9"
+)
+
+// ErrNotFound ให้ loader ของ Remember คืนค่านี้ (หรือ wrap) เมื่อไม่พบข้อมูลต้นทาง
+// เพื่อให้ Remember เก็บผลลัพธ์แบบ negative cache ได้
+var ErrNotFound = errors.New("redis: not found")
+
+const (
+	cacheEntryMagic    byte = 0xCE
+	cacheEntryNegative byte = 1 << 0
+	cacheEntryHeader        = 1 + 1 + 8 + 8
+	// xfetchMinRandom กัน ln(0) = -Inf ในสูตร XFetch
+	xfetchMinRandom = 1e-12
+)
+
+// GetJSON อ่าน key แล้ว decode JSON เป็น T
+// found เป็น false เมื่อ key ไม่มีอยู่ ส่วน err ใช้เฉพาะกรณีผิดพลาดจริง
+func GetJSON[T any](ctx context.Context, c *Client, key string) (value T, found bool, err error) {
+	data, err := c.rdbc.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == rdb.Nil {
+			return value, false, nil
+		}
+		return value, false, fmt.Errorf("failed to get key %s: %v", key, err)
+	}
+
+	if err := JSONCodec.Unmarshal(data, &value); err != nil {
+		return value, false, fmt.Errorf("failed to unmarshal key %s: %v", key, err)
+	}
+	return value, true, nil
+}
+
+// SetJSON encode value เป็น JSON แล้วเก็บด้วย ttl (0 คือไม่หมดอายุ)
+func SetJSON[T any](ctx context.Context, c *Client, key string, value T, ttl time.Duration) error {
+	data, err := JSONCodec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key %s: %v", key, err)
+	}
+	return c.rdbc.Set(ctx, key, data, ttl).Err()
+}
+
+type rememberOptions struct {
+	codec       Codec
+	beta        float64
+	negativeTTL time.Duration
+}
+
+// RememberOption ปรับพฤติกรรมของ Remember
+type RememberOption func(*rememberOptions)
+
+// WithCodec เปลี่ยน codec ที่ใช้เก็บค่า (ค่าเริ่มต้น JSONCodec)
+func WithCodec(codec Codec) RememberOption {
+	return func(o *rememberOptions) {
+		o.codec = codec
+	}
+}
+
+// WithEarlyRefresh เปิด probabilistic early refresh (XFetch)
+// beta ยิ่งมากยิ่ง refresh ก่อนหมดอายุเร็วขึ้น ค่าแนะนำคือ 1.0
+func WithEarlyRefresh(beta float64) RememberOption {
+	return func(o *rememberOptions) {
+		o.beta = beta
+	}
+}
+
+// WithNegativeTTL เก็บผล ErrNotFound จาก loader ไว้เป็นเวลา ttl
+// เพื่อไม่ให้ key ที่ไม่มีข้อมูลยิงไปที่ต้นทางซ้ำ ๆ
+func WithNegativeTTL(ttl time.Duration) RememberOption {
+	return func(o *rememberOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+type cacheEntry struct {
+	negative bool
+	delta    time.Duration
+	expiry   time.Time
+	payload  []byte
+}
+
+func (e cacheEntry) encode() []byte {
+	buf := make([]byte, cacheEntryHeader+len(e.payload))
+	buf[0] = cacheEntryMagic
+	if e.negative {
+		buf[1] |= cacheEntryNegative
+	}
+	binary.BigEndian.PutUint64(buf[2:10], uint64(e.delta.Milliseconds()))
+	if !e.expiry.IsZero() {
+		binary.BigEndian.PutUint64(buf[10:18], uint64(e.expiry.UnixMilli()))
+	}
+	copy(buf[cacheEntryHeader:], e.payload)
+	return buf
+}
+
+func decodeCacheEntry(data []byte) (cacheEntry, error) {
+	if len(data) < cacheEntryHeader || data[0] != cacheEntryMagic {
+		return cacheEntry{}, errors.New("invalid cache entry")
+	}
+	entry := cacheEntry{
+		negative: data[1]&cacheEntryNegative != 0,
+		delta:    time.Duration(binary.BigEndian.Uint64(data[2:10])) * time.Millisecond,
+		payload:  data[cacheEntryHeader:],
+	}
+	if expiry := int64(binary.BigEndian.Uint64(data[10:18])); expiry > 0 {
+		entry.expiry = time.UnixMilli(expiry)
+	}
+	return entry, nil
+}
+
+// shouldRefreshEarly ตัดสินใจตามสูตร XFetch: now - delta*beta*ln(rand) >= expiry
+func (e cacheEntry) shouldRefreshEarly(beta float64) bool {
+	if beta <= 0 || e.delta <= 0 || e.expiry.IsZero() {
+		return false
+	}
+	gap := time.Duration(-float64(e.delta) * beta * math.Log(math.Max(rand.Float64(), xfetchMinRandom)))
+	return !time.Now().Add(gap).Before(e.expiry)
+}
+
+// Remember คืนค่าจาก cache ถ้ามี ไม่เช่นนั้นเรียก loader แล้วเก็บผลไว้ ttl
+// การเรียก loader สำหรับ key เดียวกันภายใน process จะถูกรวมด้วย singleflight
+// ค่าที่เก็บโดย Remember มี header ของตัวเอง จึงอ่านด้วย GetJSON ไม่ได้
+func Remember[T any](ctx context.Context, c *Client, key string, ttl time.Duration, loader func() (T, error), opts ...RememberOption) (T, error) {
+	options := rememberOptions{codec: JSONCodec}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var zero T
+	data, err := c.rdbc.Get(ctx, key).Bytes()
+	if err != nil && err != rdb.Nil {
+		return zero, fmt.Errorf("failed to get key %s: %v", key, err)
+	}
+
+	if err == nil {
+		entry, decodeErr := decodeCacheEntry(data)
+		if decodeErr == nil && !entry.shouldRefreshEarly(options.beta) {
+			if entry.negative {
+				return zero, ErrNotFound
+			}
+			var value T
+			if err := options.codec.Unmarshal(entry.payload, &value); err == nil {
+				return value, nil
+			}
+		}
+	}
+
+	// แยก singleflight ตามชนิด T เพื่อไม่ให้ Remember ต่างชนิดบน key เดียวกันได้ค่าของกันและกัน
+	sfKey := reflect.TypeOf((*T)(nil)).Elem().String() + ":" + key
+	result, err, _ := c.sf.Do(sfKey, func() (interface{}, error) {
+		return rememberLoad(ctx, c, key, ttl, loader, options)
+	})
+	if err != nil {
+		return zero, err
+	}
+	value, _ := result.(T)
+	return value, nil
+}
+
+func rememberLoad[T any](ctx context.Context, c *Client, key string, ttl time.Duration, loader func() (T, error), options rememberOptions) (T, error) {
+	start := time.Now()
+	value, err := loader()
+	delta := time.Since(start)
+
+	if err != nil {
+		if errors.Is(err, ErrNotFound) && options.negativeTTL > 0 {
+			entry := cacheEntry{negative: true, delta: delta, expiry: time.Now().Add(options.negativeTTL)}
+			if setErr := c.rdbc.Set(ctx, key, entry.encode(), options.negativeTTL).Err(); setErr != nil {
+				log.Printf("Warning: failed to set negative cache for key %s: %v", key, setErr)
+			}
+		}
+		return value, err
+	}
+
+	payload, err := options.codec.Marshal(value)
+	if err != nil {
+		return value, fmt.Errorf("failed to marshal key %s: %v", key, err)
+	}
+
+	entry := cacheEntry{delta: delta, payload: payload}
+	if ttl > 0 {
+		entry.expiry = time.Now().Add(ttl)
+	}
+	// loader ทำงานสำเร็จแล้ว ถ้าเก็บ cache ไม่ได้ก็ยังคืนค่าให้ผู้เรียก
+	if err := c.rdbc.Set(ctx, key, entry.encode(), ttl).Err(); err != nil {
+		log.Printf("Warning: failed to set key %s: %v", key, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultClaimCheckThreshold = 256 * 1024
+	claimCheckKeyPrefix        = "tasks/"
+)
+
+// marker นำหน้า blob บอกว่า payload ถูกเก็บเป็น JSON ตรง ๆ หรือผ่าน PayloadCodec
+var (
+	claimCheckJSONMarker  = []byte("cc:json:")
+	claimCheckCodecMarker = []byte("cc:codec:")
+)
+
+// BlobStore เก็บ payload ขนาดใหญ่ของ task นอก Redis (claim-check pattern)
+// minio.BlobStore implement interface นี้
+type BlobStore interface {
+	PutBlob(ctx context.Context, key string, data []byte) error
+	GetBlob(ctx context.Context, key string) ([]byte, error)
+	DeleteBlob(ctx context.Context, key string) error
+}
+
+// WithClaimCheck ย้าย payload ที่ใหญ่กว่า minSize byte ไปเก็บใน store
+// แล้วเก็บเพียง PayloadRef ใน task ส่วน handler ยังได้ Payload ครบเหมือนเดิม
+func WithClaimCheck(store BlobStore, minSize int) TaskQueueOption {
+	return func(tq *TaskQueue) {
+		if minSize <= 0 {
+			minSize = DefaultClaimCheckThreshold
+		}
+		tq.blobStore = store
+		tq.blobMinSize = minSize
+	}
+}
+
+// offloadPayload อัปโหลด payload ไป BlobStore ถ้าใหญ่เกิน threshold
+func (tq *TaskQueue) offloadPayload(ctx context.Context, task *Task) error {
+	if tq.blobStore == nil || task.Payload == nil || task.PayloadRef != "" {
+		return nil
+	}
+
+	data, err := json.Marshal(task.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %v", err)
+	}
+	if len(data) < tq.blobMinSize {
+		return nil
+	}
+
+	marker := claimCheckJSONMarker
+	if tq.payloadCodec != nil {
+		data, err = tq.payloadCodec.Encode(data)
+		if err != nil {
+			return fmt.Errorf("failed to encode task payload: %v", err)
+		}
+		marker = claimCheckCodecMarker
+	}
+	data = append(append([]byte{}, marker...), data...)
+
+	ref := claimCheckKeyPrefix + task.ID
+	if err := tq.blobStore.PutBlob(ctx, ref, data); err != nil {
+		return fmt.Errorf("failed to store task payload: %v", err)
+	}
+	task.PayloadRef = ref
+	return nil
+}
+
+// loadPayload ดึง payload กลับจาก BlobStore โดยดู marker ที่ offloadPayload ใส่ไว้
+// blob ที่ไม่มี marker ถือว่าเป็น JSON ตรง ๆ
+func (tq *TaskQueue) loadPayload(ctx context.Context, ref string) (map[string]interface{}, error) {
+	if tq.blobStore == nil {
+		return nil, fmt.Errorf("payload %s is offloaded but no blob store is configured", ref)
+	}
+
+	data, err := tq.blobStore.GetBlob(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, claimCheckCodecMarker) {
+		if tq.payloadCodec == nil {
+			return nil, fmt.Errorf("payload %s is encoded but no payload codec is configured", ref)
+		}
+		if data, err = tq.payloadCodec.Decode(data[len(claimCheckCodecMarker):]); err != nil {
+			return nil, fmt.Errorf("failed to decode task payload: %v", err)
+		}
+	} else {
+		data = bytes.TrimPrefix(data, claimCheckJSONMarker)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task payload: %v", err)
+	}
+	return payload, nil
+}
+
+// deletePayloadBlob ลบ blob ของ task ที่ไม่ต้องใช้แล้ว
+func (tq *TaskQueue) deletePayloadBlob(ctx context.Context, task *Task) {
+	if task.PayloadRef == "" || tq.blobStore == nil {
+		return
+	}
+	if err := tq.blobStore.DeleteBlob(ctx, task.PayloadRef); err != nil {
+		log.Printf("Warning: failed to delete payload blob of task %s: %v", task.ID, err)
+	}
+}
+
+// PurgeFailedTasks ลบ task ทั้งหมดใน failed queue (DLQ) พร้อม blob และรายละเอียดของ task
+func (tq *TaskQueue) PurgeFailedTasks(ctx context.Context) (int, error) {
+	purged := 0
+	for {
+		taskJSON, err := tq.client.rdbc.RPop(ctx, tq.key(TaskFailedKey)).Result()
+		if err != nil {
+			if err == rdb.Nil {
+				return purged, nil
+			}
+			return purged, fmt.Errorf("failed to pop failed task: %v", err)
+		}
+
+		task, err := tq.decodeTask(ctx, []byte(taskJSON), false)
+		if err != nil {
+			log.Printf("Failed to unmarshal failed task: %v", err)
+			continue
+		}
+
+		tq.deletePayloadBlob(ctx, task)
+		if err := tq.client.rdbc.Del(ctx, tq.taskKey(task.ID)).Err(); err != nil {
+			log.Printf("Warning: failed to delete task details: %v", err)
+		}
+		purged++
+	}
+}
@@ -2,37 +2,133 @@ package redis
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
 	rdb "github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
+type clientOptions struct {
+	tracer     opentracing.Tracer
+	registerer prometheus.Registerer
+	name       string
+}
+
+// ClientOption ปรับการสร้าง Client
+type ClientOption func(*clientOptions)
+
+// WithTracer ติดตั้ง TracingHook เพื่อสร้าง span ต่อ command
+func WithTracer(tracer opentracing.Tracer) ClientOption {
+	return func(o *clientOptions) {
+		o.tracer = tracer
+	}
+}
+
+// WithMetrics ลงทะเบียน latency histogram และสถิติ connection pool กับ registerer
+func WithMetrics(registerer prometheus.Registerer) ClientOption {
+	return func(o *clientOptions) {
+		o.registerer = registerer
+	}
+}
+
+// WithClientName ตั้งค่า label client ของ metric connection pool (ค่าเริ่มต้น "default")
+// ต้องตั้งให้ต่างกันเมื่อมีหลาย client ใน process เดียวกันที่ลงทะเบียน metric กับ registerer เดียวกัน
+func WithClientName(name string) ClientOption {
+	return func(o *clientOptions) {
+		o.name = name
+	}
+}
+
 type Client struct {
-	rdbc    *rdb.Client
+	rdbc    rdb.UniversalClient
 	address string
+	cluster bool
+	sf      singleflight.Group
+
+	eventSource string
 }
 
-func NewClient(address string) (*Client, error) {
+func NewClient(address string, opts ...ClientOption) (*Client, error) {
 	opt, err := rdb.ParseURL(address)
 	if err != nil {
 		return nil, err
 	}
 
-	rdb := rdb.NewClient(opt)
+	return newClient(rdb.NewClient(opt), address, opts...)
+}
+
+// NewFailoverClient connects to a master managed by Redis Sentinel
+func NewFailoverClient(opt *rdb.FailoverOptions, opts ...ClientOption) (*Client, error) {
+	address := opt.MasterName + "@" + strings.Join(opt.SentinelAddrs, ",")
+	return newClient(rdb.NewFailoverClient(opt), address, opts...)
+}
+
+// NewClusterClient connects to a Redis Cluster. Keys used together in a
+// multi-key command must share a hash tag, see HashTag.
+func NewClusterClient(opt *rdb.ClusterOptions, opts ...ClientOption) (*Client, error) {
+	address := strings.Join(opt.Addrs, ",")
+	return newClient(rdb.NewClusterClient(opt), address, opts...)
+}
+
+// NewUniversalClient picks single-node, sentinel or cluster from the options
+func NewUniversalClient(opt *rdb.UniversalOptions, opts ...ClientOption) (*Client, error) {
+	address := strings.Join(opt.Addrs, ",")
+	return newClient(rdb.NewUniversalClient(opt), address, opts...)
+}
+
+func newClient(rdbc rdb.UniversalClient, address string, opts ...ClientOption) (*Client, error) {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.tracer != nil || options.registerer != nil {
+		hook := NewTracingHook(options.tracer)
+		if options.registerer != nil {
+			name := options.name
+			if name == "" {
+				name = "default"
+			}
+			metrics, err := newClientMetrics(options.registerer, rdbc, name)
+			if err != nil {
+				rdbc.Close()
+				return nil, err
+			}
+			hook.metrics = metrics
+		}
+		rdbc.AddHook(hook)
+	}
 
-	cmd := rdb.Ping(context.Background())
+	cmd := rdbc.Ping(context.Background())
 	if err := cmd.Err(); err != nil {
+		rdbc.Close()
 		return nil, err
 	}
 
+	_, cluster := rdbc.(*rdb.ClusterClient)
 	client := &Client{
-		rdbc:    rdb,
+		rdbc:    rdbc,
 		address: address,
+		cluster: cluster,
 	}
 
 	return client, nil
 }
 
+// HashTag prefixes key with {tag} so every key sharing the tag lands in the
+// same cluster slot
+func HashTag(tag string, key string) string {
+	return "{" + tag + "}:" + key
+}
+
+// IsCluster reports whether the client talks to a Redis Cluster
+func (c *Client) IsCluster() bool {
+	return c.cluster
+}
+
 func (c *Client) Set(ctx context.Context, key string, val interface{}, expire time.Duration) error {
 	return c.rdbc.Set(ctx, key, val, expire).Err()
 }
@@ -55,7 +151,15 @@ func (c *Client) Close() error {
 	return c.rdbc.Close()
 }
 
-// GetRedisClient returns the underlying Redis client for advanced operations
+// GetRedisClient returns the underlying Redis client for advanced operations.
+// It returns nil in cluster mode; use GetUniversalClient to support every topology.
 func (c *Client) GetRedisClient() *rdb.Client {
+	client, _ := c.rdbc.(*rdb.Client)
+	return client
+}
+
+// GetUniversalClient returns the underlying client as rdb.UniversalClient.
+// The concrete type is *rdb.Client or *rdb.ClusterClient depending on the topology.
+func (c *Client) GetUniversalClient() rdb.UniversalClient {
 	return c.rdbc
 }
@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec แปลง value เป็น bytes ก่อนเก็บลง Redis และแปลงกลับตอนอ่าน
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec encodes values with encoding/json
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec encodes values with msgpack, smaller and faster than JSON
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type gzipCodec struct {
+	inner Codec
+	level int
+}
+
+// NewGzipCodec ห่อ codec อื่นด้วย gzip เหมาะกับ value ขนาดใหญ่
+func NewGzipCodec(inner Codec, level int) Codec {
+	if inner == nil {
+		inner = JSONCodec
+	}
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzipCodec{inner: inner, level: level}
+}
+
+func (g gzipCodec) Marshal(v interface{}) ([]byte, error) {
+	raw, err := g.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, g.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g gzipCodec) Unmarshal(data []byte, v interface{}) error {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+	return g.inner.Unmarshal(raw, v)
+}
@@ -0,0 +1,208 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+var (
+	numericSegmentReg = regexp.MustCompile(`^[0-9]+$`)
+	uuidSegmentReg    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	tokenSegmentReg   = regexp.MustCompile(`^[A-Za-z0-9_\-+/=]{20,}$`)
+)
+
+// TracingHook สร้าง child span ต่อ command/pipeline และเก็บ metrics ของ Redis
+type TracingHook struct {
+	tracer  opentracing.Tracer
+	metrics *clientMetrics
+}
+
+func NewTracingHook(tracer opentracing.Tracer) *TracingHook {
+	return &TracingHook{
+		tracer: tracer,
+	}
+}
+
+// keyPattern ซ่อนส่วนของ key ที่เป็น id เช่น "task:6ba7b810-..." จะกลายเป็น "task:*"
+// เพื่อไม่ให้ span มีค่าจริงและให้ metrics มี cardinality ต่ำ
+func keyPattern(key string) string {
+	segments := strings.Split(key, ":")
+	for i, segment := range segments {
+		if numericSegmentReg.MatchString(segment) || uuidSegmentReg.MatchString(segment) || tokenSegmentReg.MatchString(segment) {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, ":")
+}
+
+// commandStatement คืนชื่อ command ตามด้วย key pattern โดยไม่ใส่ value
+func commandStatement(cmd rdb.Cmder) string {
+	args := cmd.Args()
+	statement := strings.ToUpper(cmd.Name())
+	if len(args) > 1 {
+		if key, ok := args[1].(string); ok {
+			statement += " " + keyPattern(key)
+		}
+	}
+	return statement
+}
+
+func (h *TracingHook) startSpan(ctx context.Context, operationName string) (opentracing.Span, context.Context) {
+	if h.tracer == nil {
+		return nil, ctx
+	}
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
+		return nil, ctx
+	}
+
+	span := h.tracer.StartSpan(operationName, opentracing.ChildOf(parent.Context()))
+	ext.DBType.Set(span, "redis")
+	ext.SpanKindRPCClient.Set(span)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+func finishSpan(span opentracing.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil && err != rdb.Nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+	}
+	span.Finish()
+}
+
+func (h *TracingHook) DialHook(next rdb.DialHook) rdb.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *TracingHook) ProcessHook(next rdb.ProcessHook) rdb.ProcessHook {
+	return func(ctx context.Context, cmd rdb.Cmder) error {
+		span, spanCtx := h.startSpan(ctx, "redis."+cmd.Name())
+		if span != nil {
+			ext.DBStatement.Set(span, commandStatement(cmd))
+		}
+
+		start := time.Now()
+		err := next(spanCtx, cmd)
+		h.metrics.observe(cmd.Name(), time.Since(start), err)
+
+		finishSpan(span, err)
+		return err
+	}
+}
+
+func (h *TracingHook) ProcessPipelineHook(next rdb.ProcessPipelineHook) rdb.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []rdb.Cmder) error {
+		span, spanCtx := h.startSpan(ctx, "redis.pipeline")
+		if span != nil {
+			statements := make([]string, 0, len(cmds))
+			for _, cmd := range cmds {
+				statements = append(statements, commandStatement(cmd))
+			}
+			span.SetTag("db.redis.num_cmd", len(cmds))
+			ext.DBStatement.Set(span, strings.Join(statements, "\n"))
+		}
+
+		start := time.Now()
+		err := next(spanCtx, cmds)
+		h.metrics.observe("pipeline", time.Since(start), err)
+
+		finishSpan(span, err)
+		return err
+	}
+}
+
+// clientMetrics เก็บ latency ต่อ command และสถิติ connection pool สำหรับ Prometheus
+type clientMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+func newClientMetrics(registerer prometheus.Registerer, rdbc rdb.UniversalClient, clientName string) (*clientMetrics, error) {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Latency of Redis commands",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "status"})
+
+	labels := prometheus.Labels{"client": clientName}
+	poolGauge := func(name, help string, value func(stats *rdb.PoolStats) uint32) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   "redis",
+			Subsystem:   "pool",
+			Name:        name,
+			Help:        help,
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(value(rdbc.PoolStats()))
+		})
+	}
+	// hits/misses/timeouts/stale เป็นค่าสะสมตั้งแต่เปิด client จึงเป็น counter
+	poolCounter := func(name, help string, value func(stats *rdb.PoolStats) uint32) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   "redis",
+			Subsystem:   "pool",
+			Name:        name,
+			Help:        help,
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(value(rdbc.PoolStats()))
+		})
+	}
+
+	if err := registerer.Register(duration); err != nil {
+		are, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			return nil, err
+		}
+		// client ตัวที่สองใน process เดียวกันใช้ histogram ร่วมกัน
+		duration = are.ExistingCollector.(*prometheus.HistogramVec)
+	}
+
+	collectors := []prometheus.Collector{
+		poolCounter("hits_total", "Number of times a free connection was found in the pool", func(s *rdb.PoolStats) uint32 { return s.Hits }),
+		poolCounter("misses_total", "Number of times a free connection was not found in the pool", func(s *rdb.PoolStats) uint32 { return s.Misses }),
+		poolCounter("timeouts_total", "Number of times a wait timeout occurred", func(s *rdb.PoolStats) uint32 { return s.Timeouts }),
+		poolGauge("total_conns", "Number of total connections in the pool", func(s *rdb.PoolStats) uint32 { return s.TotalConns }),
+		poolGauge("idle_conns", "Number of idle connections in the pool", func(s *rdb.PoolStats) uint32 { return s.IdleConns }),
+		poolCounter("stale_conns_total", "Number of stale connections removed from the pool", func(s *rdb.PoolStats) uint32 { return s.StaleConns }),
+	}
+	for i, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			for _, registered := range collectors[:i] {
+				registerer.Unregister(registered)
+			}
+			if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				return nil, fmt.Errorf("pool metrics for client %q are already registered, use WithClientName to set a unique name: %v", clientName, err)
+			}
+			return nil, err
+		}
+	}
+
+	return &clientMetrics{duration: duration}, nil
+}
+
+func (m *clientMetrics) observe(command string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	status := "ok"
+	if err != nil && err != rdb.Nil {
+		status = "error"
+	}
+	m.duration.WithLabelValues(command, status).Observe(elapsed.Seconds())
+}
@@ -0,0 +1,219 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	DefaultIdempotencyTTL     = 24 * time.Hour
+	DefaultIdempotencyLockTTL = time.Minute
+	idempotencyKeyPrefix      = "idempotency:"
+)
+
+type idempotencyStatus string
+
+const (
+	idempotencyProcessing idempotencyStatus = "processing"
+	idempotencyCompleted  idempotencyStatus = "completed"
+)
+
+// idempotencyReleaseScript ลบ key เฉพาะเมื่อยังเป็น lock ของ request นี้
+var idempotencyReleaseScript = rdb.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// idempotencyStoreScript เขียน response ทับ lock เฉพาะเมื่อยังเป็น lock ของ request นี้
+var idempotencyStoreScript = rdb.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+type idempotencyRecord struct {
+	Status      idempotencyStatus   `json:"status"`
+	Token       string              `json:"token,omitempty"`
+	RequestHash string              `json:"request_hash"`
+	StatusCode  int                 `json:"status_code,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+type IdempotencyConfig struct {
+	Client *Client
+	// TTL อายุของ response ที่เก็บไว้ replay
+	TTL time.Duration
+	// LockTTL อายุของสถานะ processing กันกรณี process ตายระหว่างทำงาน
+	LockTTL time.Duration
+	// Methods ที่บังคับใช้ ค่าเริ่มต้นคือ POST
+	Methods []string
+	// Required ตอบ 400 ถ้า request ไม่มี Idempotency-Key
+	Required bool
+	// ScopeFunc แยก key ตามผู้ใช้หรือ tenant เพื่อไม่ให้ key ของคนละคนชนกัน เช่น RateLimitByJWTSubject("payload")
+	ScopeFunc func(c echo.Context) string
+}
+
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *idempotencyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware เก็บ response แรกของแต่ละ Idempotency-Key แล้ว replay ให้ request ซ้ำ
+// ตอบ 409 ถ้า request แรกยังทำงานไม่เสร็จ และ 422 ถ้า key เดิมถูกใช้กับ body อื่น
+// response ที่เป็น 5xx จะไม่ถูกเก็บ เพื่อให้ client retry ได้
+func IdempotencyMiddleware(config IdempotencyConfig) echo.MiddlewareFunc {
+	if config.TTL <= 0 {
+		config.TTL = DefaultIdempotencyTTL
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = DefaultIdempotencyLockTTL
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{http.MethodPost}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !containsMethod(config.Methods, c.Request().Method) {
+				return next(c)
+			}
+
+			idempotencyKey := c.Request().Header.Get(IdempotencyKeyHeader)
+			if idempotencyKey == "" {
+				if config.Required {
+					return echo.NewHTTPError(http.StatusBadRequest, IdempotencyKeyHeader+" header is required")
+				}
+				return next(c)
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := sha256.Sum256(append([]byte(c.Request().Method+" "+c.Path()+"\n"), body...))
+			requestHash := hex.EncodeToString(hash[:])
+
+			key := idempotencyKeyPrefix
+			if config.ScopeFunc != nil {
+				key += config.ScopeFunc(c) + ":"
+			}
+			key += idempotencyKey
+
+			ctx := c.Request().Context()
+			token, _ := uuid.NewV4()
+			lockJSON, _ := json.Marshal(idempotencyRecord{Status: idempotencyProcessing, Token: token.String(), RequestHash: requestHash})
+			acquired, err := config.Client.rdbc.SetNX(ctx, key, lockJSON, config.LockTTL).Result()
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+
+			if !acquired {
+				return replayIdempotentResponse(c, config.Client, key, requestHash)
+			}
+
+			recorder := &idempotencyRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			handlerErr := next(c)
+
+			// handler ทำงานเสร็จแล้ว ต้องบันทึกผลแม้ client ตัดการเชื่อมต่อไปแล้ว
+			// ไม่เช่นนั้น retry หลัง LockTTL หมดจะทำงานซ้ำ
+			storeCtx := context.WithoutCancel(ctx)
+			res := c.Response()
+			if (handlerErr != nil && !res.Committed) || res.Status >= http.StatusInternalServerError {
+				// ปล่อย key ให้ client retry ได้
+				if err := idempotencyReleaseScript.Run(storeCtx, config.Client.rdbc, []string{key}, lockJSON).Err(); err != nil {
+					log.Printf("Warning: failed to release idempotency key %s: %v", idempotencyKey, err)
+				}
+				return handlerErr
+			}
+
+			record := idempotencyRecord{
+				Status:      idempotencyCompleted,
+				RequestHash: requestHash,
+				StatusCode:  res.Status,
+				Header:      res.Header().Clone(),
+				Body:        recorder.body.Bytes(),
+			}
+			recordJSON, err := json.Marshal(record)
+			if err != nil {
+				log.Printf("Warning: failed to marshal idempotent response for key %s: %v", idempotencyKey, err)
+				return handlerErr
+			}
+			stored, err := idempotencyStoreScript.Run(storeCtx, config.Client.rdbc, []string{key}, lockJSON, recordJSON, config.TTL.Milliseconds()).Int()
+			if err != nil {
+				log.Printf("Warning: failed to store idempotent response for key %s: %v", idempotencyKey, err)
+			} else if stored == 0 {
+				log.Printf("Warning: idempotency lock for key %s expired before the response was stored", idempotencyKey)
+			}
+
+			return handlerErr
+		}
+	}
+}
+
+func replayIdempotentResponse(c echo.Context, client *Client, key string, requestHash string) error {
+	result, err := client.rdbc.Get(c.Request().Context(), key).Bytes()
+	if err != nil {
+		if err == rdb.Nil {
+			// request แรกเพิ่งล้มเหลวและปล่อย key ไป ให้ client ลองใหม่
+			return echo.NewHTTPError(http.StatusConflict, "request with the same idempotency key was not completed, please retry")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(result, &record); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if record.RequestHash != requestHash {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "idempotency key was already used with a different request")
+	}
+	if record.Status == idempotencyProcessing {
+		return echo.NewHTTPError(http.StatusConflict, "request with the same idempotency key is in progress")
+	}
+
+	header := c.Response().Header()
+	for name, values := range record.Header {
+		header[name] = values
+	}
+	header.Set(IdempotencyReplayedHeader, "true")
+	c.Response().WriteHeader(record.StatusCode)
+	_, err = c.Response().Write(record.Body)
+	return err
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
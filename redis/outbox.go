@@ -0,0 +1,329 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultOutboxTable           = "task_outbox"
+	DefaultOutboxBatchSize       = 100
+	DefaultOutboxPollInterval    = time.Second
+	DefaultOutboxRetention       = 7 * 24 * time.Hour
+	DefaultOutboxCleanupInterval = time.Hour
+	DefaultOutboxClaimTimeout    = 5 * time.Minute
+)
+
+// OutboxSchema คือ DDL ของตาราง outbox สำหรับ PostgreSQL ใช้กับ fmt.Sprintf ร่วมกับชื่อตาราง
+// ALTER TABLE ใช้เพิ่ม column ให้ตารางที่สร้างจาก schema เวอร์ชันก่อน
+const OutboxSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id          BIGSERIAL PRIMARY KEY,
+	task_id     UUID NOT NULL,
+	task_type   TEXT NOT NULL,
+	tenant_id   TEXT NOT NULL DEFAULT '',
+	payload     JSONB,
+	max_retries INT NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	claimed_at  TIMESTAMPTZ,
+	sent_at     TIMESTAMPTZ,
+	failed_at   TIMESTAMPTZ,
+	error_msg   TEXT
+);
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ;
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS error_msg TEXT;
+DROP INDEX IF EXISTS %[1]s_unsent_idx;
+CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (id) WHERE sent_at IS NULL AND failed_at IS NULL;`
+
+type OutboxConfig struct {
+	// Table ชื่อตาราง outbox
+	Table string
+	// BatchSize จำนวน row สูงสุดที่ relay ส่งต่อรอบ
+	BatchSize int
+	// PollInterval ระยะเวลาระหว่างรอบเมื่อไม่มี row ค้าง
+	PollInterval time.Duration
+	// Retention อายุของ row ที่ส่งแล้วก่อนถูกลบโดย cleanup
+	Retention time.Duration
+	// CleanupInterval ระยะเวลาระหว่างการ cleanup
+	CleanupInterval time.Duration
+	// ClaimTimeout ระยะเวลาที่ row ที่ถูก claim แล้วแต่ยังไม่ถูกส่ง (เช่น relay ล้ม) จะถูก claim ใหม่ได้
+	ClaimTimeout time.Duration
+}
+
+// Outbox ส่ง task จาก PostgreSQL เข้า TaskQueue แบบ transactional outbox
+// task ถูกเขียนลงตารางใน transaction เดียวกับข้อมูลของ handler แล้ว relay จะ claim row ตามลำดับ id
+// ด้วย FOR UPDATE SKIP LOCKED ทำให้รัน relay หลาย replica พร้อมกันได้
+// ลำดับตาม id รับประกันเฉพาะภายใน batch เดียวเมื่อรัน relay เพียงตัวเดียวเท่านั้น
+// ถ้ารันหลาย relay batch ถัดไปอาจถูกส่งก่อน batch ก่อนหน้า และ row ที่ claim ค้างจนหมดเวลาจะถูกส่งทีหลัง row ที่ใหม่กว่า
+// ถ้า task ของ aggregate เดียวกันต้องเรียงกัน ให้รัน relay ตัวเดียวหรือให้ handler ตรวจลำดับเอง
+// การส่งเป็นแบบ at-least-once: task อาจถูก enqueue ซ้ำด้วย id เดิมถ้า relay ล้มก่อนบันทึกว่าส่งแล้ว
+type Outbox struct {
+	db       *sqlx.DB
+	queue    *TaskQueue
+	config   OutboxConfig
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	running  bool
+}
+
+type outboxRow struct {
+	ID         int64     `db:"id"`
+	TaskID     string    `db:"task_id"`
+	TaskType   string    `db:"task_type"`
+	TenantID   string    `db:"tenant_id"`
+	Payload    []byte    `db:"payload"`
+	MaxRetries int       `db:"max_retries"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// NewOutbox สร้าง outbox บน db (เช่น psql.Client.GetClient()) ที่ส่ง task เข้า queue
+func NewOutbox(db *sqlx.DB, queue *TaskQueue, config OutboxConfig) *Outbox {
+	if config.Table == "" {
+		config.Table = DefaultOutboxTable
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultOutboxBatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultOutboxPollInterval
+	}
+	if config.Retention <= 0 {
+		config.Retention = DefaultOutboxRetention
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = DefaultOutboxCleanupInterval
+	}
+	if config.ClaimTimeout <= 0 {
+		config.ClaimTimeout = DefaultOutboxClaimTimeout
+	}
+
+	return &Outbox{
+		db:       db,
+		queue:    queue,
+		config:   config,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// CreateTable สร้างตาราง outbox ถ้ายังไม่มี
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	if _, err := o.db.ExecContext(ctx, fmt.Sprintf(OutboxSchema, o.config.Table)); err != nil {
+		return fmt.Errorf("failed to create outbox table: %v", err)
+	}
+	return nil
+}
+
+// Add เขียน task ลง outbox ภายใน transaction ของผู้เรียก task จะถูก enqueue หลัง commit เท่านั้น
+// และจะไม่ถูก enqueue เลยถ้า transaction rollback
+func (o *Outbox) Add(ctx context.Context, tx *sqlx.Tx, taskType TaskType, payload map[string]interface{}) (string, error) {
+	return o.AddTenantTask(ctx, tx, "", taskType, payload)
+}
+
+// AddTenantTask เหมือน Add แต่ระบุ tenant ของ task เพื่อให้ถูก dequeue แบบ fair ตาม WithTenantFairness
+func (o *Outbox) AddTenantTask(ctx context.Context, tx *sqlx.Tx, tenantID string, taskType TaskType, payload map[string]interface{}) (string, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal task payload: %v", err)
+	}
+
+	taskID, _ := uuid.NewV4()
+	query := fmt.Sprintf(`INSERT INTO %s (task_id, task_type, tenant_id, payload, max_retries) VALUES ($1, $2, $3, $4, $5)`, o.config.Table)
+	if _, err := tx.ExecContext(ctx, query, taskID.String(), taskType.String(), tenantID, payloadJSON, DefaultRetryLimit); err != nil {
+		return "", fmt.Errorf("failed to insert outbox task: %v", err)
+	}
+	return taskID.String(), nil
+}
+
+// Relay claim row ที่ยังไม่ส่งหนึ่ง batch แล้ว enqueue นอก transaction คืนจำนวน task ที่ส่งได้
+// row ที่ payload เสียจะถูกบันทึกเป็น failed และไม่ถูกส่งอีก
+// ถ้า enqueue ล้มเหลว row ที่เหลือจะถูกปล่อย claim เพื่อรอรอบถัดไป
+func (o *Outbox) Relay(ctx context.Context) (int, error) {
+	rows, err := o.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sentIDs := make([]int64, 0, len(rows))
+	var relayErr error
+	for i, row := range rows {
+		task := &Task{
+			ID:         row.TaskID,
+			Type:       TaskType(row.TaskType),
+			TenantID:   row.TenantID,
+			Status:     TaskStatusPending,
+			MaxRetries: row.MaxRetries,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  time.Now(),
+		}
+		if len(row.Payload) > 0 {
+			if err := json.Unmarshal(row.Payload, &task.Payload); err != nil {
+				o.markFailed(ctx, row.ID, fmt.Sprintf("failed to unmarshal outbox payload: %v", err))
+				continue
+			}
+		}
+		if err := o.queue.enqueue(ctx, task); err != nil {
+			relayErr = err
+			o.releaseClaims(ctx, rows[i:])
+			break
+		}
+		sentIDs = append(sentIDs, row.ID)
+	}
+
+	if len(sentIDs) > 0 {
+		query, args, err := sqlx.In(fmt.Sprintf(`UPDATE %s SET sent_at = now() WHERE id IN (?)`, o.config.Table), sentIDs)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := o.db.ExecContext(ctx, o.db.Rebind(query), args...); err != nil {
+			return 0, fmt.Errorf("failed to mark outbox tasks as sent: %v", err)
+		}
+	}
+
+	return len(sentIDs), relayErr
+}
+
+// claim จอง row ที่ยังไม่ส่งใน transaction สั้น ๆ ด้วย FOR UPDATE SKIP LOCKED
+// row ที่ถูก claim ไว้นานเกิน ClaimTimeout ถือว่า relay ตัวเดิมล้มและ claim ใหม่ได้
+func (o *Outbox) claim(ctx context.Context) ([]outboxRow, error) {
+	rows := []outboxRow{}
+	query := fmt.Sprintf(`UPDATE %[1]s SET claimed_at = now() WHERE id IN (
+	SELECT id FROM %[1]s
+	WHERE sent_at IS NULL AND failed_at IS NULL AND (claimed_at IS NULL OR claimed_at < $1)
+	ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED
+) RETURNING id, task_id, task_type, tenant_id, payload, max_retries, created_at`, o.config.Table)
+	if err := o.db.SelectContext(ctx, &rows, query, time.Now().Add(-o.config.ClaimTimeout), o.config.BatchSize); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox tasks: %v", err)
+	}
+
+	// RETURNING ไม่รับประกันลำดับ
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows, nil
+}
+
+// markFailed บันทึก row ที่ส่งไม่ได้ถาวร เพื่อไม่ให้ถูก claim ซ้ำทุกรอบ
+func (o *Outbox) markFailed(ctx context.Context, id int64, errorMsg string) {
+	log.Printf("Warning: outbox task %d is marked as failed: %s", id, errorMsg)
+	query := fmt.Sprintf(`UPDATE %s SET failed_at = now(), error_msg = $1 WHERE id = $2`, o.config.Table)
+	if _, err := o.db.ExecContext(ctx, query, errorMsg, id); err != nil {
+		log.Printf("Warning: failed to mark outbox task %d as failed: %v", id, err)
+	}
+}
+
+// releaseClaims ปล่อย claim ของ row ที่ยังไม่ได้ส่งเพื่อให้รอบถัดไปส่งได้ทันทีโดยไม่ต้องรอ ClaimTimeout
+func (o *Outbox) releaseClaims(ctx context.Context, rows []outboxRow) {
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	query, args, err := sqlx.In(fmt.Sprintf(`UPDATE %s SET claimed_at = NULL WHERE id IN (?)`, o.config.Table), ids)
+	if err != nil {
+		log.Printf("Warning: failed to release outbox claims: %v", err)
+		return
+	}
+	if _, err := o.db.ExecContext(ctx, o.db.Rebind(query), args...); err != nil {
+		log.Printf("Warning: failed to release outbox claims: %v", err)
+	}
+}
+
+// Cleanup ลบ row ที่ส่งแล้วและเก่ากว่า Retention คืนจำนวน row ที่ลบ
+func (o *Outbox) Cleanup(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at < $1`, o.config.Table)
+	result, err := o.db.ExecContext(ctx, query, time.Now().Add(-o.config.Retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup outbox: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// Start เริ่ม relay และ cleanup เป็น background
+func (o *Outbox) Start(ctx context.Context) {
+	o.mu.Lock()
+	if o.running {
+		o.mu.Unlock()
+		return
+	}
+	o.running = true
+	o.mu.Unlock()
+
+	o.wg.Add(2)
+	go o.relayLoop(ctx)
+	go o.cleanupLoop(ctx)
+
+	log.Println("Starting Outbox relay successfully!!")
+}
+
+// Stop หยุด relay และรอรอบที่ทำงานอยู่ให้จบ
+func (o *Outbox) Stop() {
+	o.mu.Lock()
+	if !o.running {
+		o.mu.Unlock()
+		return
+	}
+	o.running = false
+	o.mu.Unlock()
+
+	close(o.stopChan)
+	o.wg.Wait()
+
+	log.Println("Stopping Outbox relay successfully!!")
+}
+
+func (o *Outbox) relayLoop(ctx context.Context) {
+	defer o.wg.Done()
+
+	for {
+		sent, err := o.Relay(ctx)
+		if err != nil {
+			log.Printf("Failed to relay outbox tasks: %v", err)
+		}
+
+		// batch เต็มแปลว่าน่าจะยังมี row ค้าง ให้ทำรอบถัดไปทันที
+		wait := o.config.PollInterval
+		if err == nil && sent == o.config.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-o.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (o *Outbox) cleanupLoop(ctx context.Context) {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(o.config.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := o.Cleanup(ctx)
+			if err != nil {
+				log.Printf("Failed to cleanup outbox: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Cleaned up %d sent outbox tasks", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package redis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// PayloadCodec แปลง payload ของ task (JSON) ก่อนเก็บลง Redis
+// ผลลัพธ์ของ Encode ต้องมี header บอกวิธี encode เพื่อให้ Decode อ่าน task เก่าได้เสมอ
+type PayloadCodec interface {
+	Encode(payload []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+type PayloadCompression string
+
+const (
+	CompressionNone PayloadCompression = ""
+	CompressionGzip PayloadCompression = "gzip"
+	CompressionZstd PayloadCompression = "zstd"
+
+	DefaultCompressThreshold = 1024
+
+	// รูปแบบ encoded payload เวอร์ชัน 1:
+	// [version][flags][key id length][key id][nonce][data]
+	// ส่วน key id และ nonce มีเฉพาะเมื่อเข้ารหัส
+	payloadFormatV1   byte = 1
+	payloadFlagGzip   byte = 1 << 0
+	payloadFlagZstd   byte = 1 << 1
+	payloadFlagAESGCM byte = 1 << 2
+)
+
+type PayloadCodecConfig struct {
+	// Compression วิธีบีบอัด payload ที่ใหญ่กว่า CompressThreshold byte
+	Compression       PayloadCompression
+	CompressThreshold int
+	// Keys คือ AES key (16, 24 หรือ 32 byte) ตาม key id ต้องเก็บ key เก่าไว้จนกว่า task ที่ใช้ key นั้นจะหมด
+	Keys map[string][]byte
+	// ActiveKeyID คือ key ที่ใช้เข้ารหัส task ใหม่ ถ้าว่างจะไม่เข้ารหัส
+	ActiveKeyID string
+}
+
+type payloadCodec struct {
+	config PayloadCodecConfig
+	aeads  map[string]cipher.AEAD
+	zenc   *zstd.Encoder
+	zdec   *zstd.Decoder
+}
+
+// NewPayloadCodec สร้าง codec ที่บีบอัดและ/หรือเข้ารหัส payload ด้วย AES-GCM
+func NewPayloadCodec(config PayloadCodecConfig) (PayloadCodec, error) {
+	if config.CompressThreshold <= 0 {
+		config.CompressThreshold = DefaultCompressThreshold
+	}
+
+	codec := &payloadCodec{
+		config: config,
+		aeads:  make(map[string]cipher.AEAD),
+	}
+
+	for keyID, key := range config.Keys {
+		if len(keyID) == 0 || len(keyID) > 255 {
+			return nil, fmt.Errorf("invalid key id length: %q", keyID)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %v", keyID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %v", keyID, err)
+		}
+		codec.aeads[keyID] = aead
+	}
+	if config.ActiveKeyID != "" {
+		if _, exists := codec.aeads[config.ActiveKeyID]; !exists {
+			return nil, fmt.Errorf("active key %s not found", config.ActiveKeyID)
+		}
+	}
+
+	var err error
+	if codec.zenc, err = zstd.NewWriter(nil); err != nil {
+		return nil, err
+	}
+	if codec.zdec, err = zstd.NewReader(nil); err != nil {
+		return nil, err
+	}
+
+	return codec, nil
+}
+
+func (c *payloadCodec) Encode(payload []byte) ([]byte, error) {
+	var flags byte
+	data := payload
+
+	if len(payload) >= c.config.CompressThreshold {
+		switch c.config.Compression {
+		case CompressionGzip:
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			if _, err := zw.Write(payload); err != nil {
+				return nil, err
+			}
+			if err := zw.Close(); err != nil {
+				return nil, err
+			}
+			data = buf.Bytes()
+			flags |= payloadFlagGzip
+		case CompressionZstd:
+			data = c.zenc.EncodeAll(payload, nil)
+			flags |= payloadFlagZstd
+		}
+	}
+
+	header := []byte{payloadFormatV1, flags}
+	if c.config.ActiveKeyID == "" {
+		return append(header, data...), nil
+	}
+
+	aead := c.aeads[c.config.ActiveKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header[1] |= payloadFlagAESGCM
+	header = append(header, byte(len(c.config.ActiveKeyID)))
+	header = append(header, c.config.ActiveKeyID...)
+	header = append(header, nonce...)
+	// ใช้ header เป็น additional data เพื่อกันการแก้ flags หรือ key id
+	out := append([]byte(nil), header...)
+	return aead.Seal(out, nonce, data, header), nil
+}
+
+func (c *payloadCodec) Decode(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, errors.New("encoded payload is too short")
+	}
+	if data[0] != payloadFormatV1 {
+		return nil, fmt.Errorf("unsupported payload format version %d", data[0])
+	}
+
+	flags := data[1]
+	body := data[2:]
+
+	if flags&payloadFlagAESGCM != 0 {
+		if len(body) < 1 || len(body) < 1+int(body[0]) {
+			return nil, errors.New("encoded payload has invalid key id")
+		}
+		keyID := string(body[1 : 1+int(body[0])])
+		aead, exists := c.aeads[keyID]
+		if !exists {
+			return nil, fmt.Errorf("payload key %s not found", keyID)
+		}
+
+		headerLen := 2 + 1 + len(keyID) + aead.NonceSize()
+		if len(data) < headerLen {
+			return nil, errors.New("encoded payload has invalid nonce")
+		}
+		header := data[:headerLen]
+		nonce := data[headerLen-aead.NonceSize() : headerLen]
+
+		plain, err := aead.Open(nil, nonce, data[headerLen:], header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt payload: %v", err)
+		}
+		body = plain
+	}
+
+	switch {
+	case flags&payloadFlagGzip != 0:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case flags&payloadFlagZstd != 0:
+		return c.zdec.DecodeAll(body, nil)
+	}
+	return body, nil
+}
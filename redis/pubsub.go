@@ -0,0 +1,238 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultHandlerConcurrency = 10
+	eventBusPrefix            = "event:"
+)
+
+// Event คือ envelope ที่ส่งผ่าน pub/sub ทุกครั้ง
+type Event struct {
+	ID     string          `json:"id"`
+	Topic  string          `json:"topic"`
+	Source string          `json:"source"`
+	Time   time.Time       `json:"time"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Bind decode Data ของ event ลงใน v
+func (e *Event) Bind(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// DecodeEvent decode Data ของ event เป็น T
+func DecodeEvent[T any](e *Event) (T, error) {
+	var v T
+	err := e.Bind(&v)
+	return v, err
+}
+
+type EventHandler func(ctx context.Context, event *Event) error
+
+type subscribeOptions struct {
+	concurrency   int
+	onResubscribe func()
+}
+
+// SubscribeOption ปรับพฤติกรรมของ Subscribe
+type SubscribeOption func(*subscribeOptions)
+
+// WithHandlerConcurrency จำกัดจำนวน handler ที่ทำงานพร้อมกันต่อ subscription
+func WithHandlerConcurrency(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithOnResubscribe เรียก fn ทุกครั้งที่ subscribe ใหม่หลังการเชื่อมต่อหลุด
+// event ที่ publish ระหว่างหลุดจะหายไป จึงใช้ล้าง state ที่อาศัย event เหล่านั้นได้
+func WithOnResubscribe(fn func()) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.onResubscribe = fn
+	}
+}
+
+// SetEventSource กำหนดชื่อ source ที่ใส่ใน event ที่ publish (ค่าเริ่มต้นคือ hostname)
+func (c *Client) SetEventSource(source string) {
+	c.eventSource = source
+}
+
+func (c *Client) getEventSource() string {
+	if c.eventSource != "" {
+		return c.eventSource
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+// Publish ห่อ data ด้วย Event แล้ว publish ไปที่ topic
+func (c *Client) Publish(ctx context.Context, topic string, data interface{}) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event data: %v", err)
+	}
+
+	eventID, _ := uuid.NewV4()
+	event := &Event{
+		ID:     eventID.String(),
+		Topic:  topic,
+		Source: c.getEventSource(),
+		Time:   time.Now(),
+		Data:   raw,
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	if err := c.rdbc.Publish(ctx, eventBusPrefix+topic, eventJSON).Err(); err != nil {
+		return nil, fmt.Errorf("failed to publish event: %v", err)
+	}
+	return event, nil
+}
+
+// Subscription คือการ subscribe ที่กำลังทำงาน ต้องเรียก Close เมื่อเลิกใช้
+type Subscription struct {
+	pubsub        *rdb.PubSub
+	handler       EventHandler
+	onResubscribe func()
+	sem           chan struct{}
+	cancel        context.CancelFunc
+	done          chan struct{}
+	wg            sync.WaitGroup
+	once          sync.Once
+}
+
+// Subscribe ฟัง topic แล้วเรียก handler ทุกครั้งที่มี event
+// topic ที่มี *, ? หรือ [ จะใช้ pattern subscription (PSUBSCRIBE)
+// เมื่อการเชื่อมต่อหลุด go-redis จะ reconnect และ subscribe ใหม่ให้เอง
+func (c *Client) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) (*Subscription, error) {
+	options := subscribeOptions{concurrency: DefaultHandlerConcurrency}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.concurrency <= 0 {
+		options.concurrency = 1
+	}
+
+	channel := eventBusPrefix + topic
+	var pubsub *rdb.PubSub
+	if strings.ContainsAny(topic, "*?[") {
+		pubsub = c.rdbc.PSubscribe(ctx, channel)
+	} else {
+		pubsub = c.rdbc.Subscribe(ctx, channel)
+	}
+
+	// รอ confirmation จาก server ก่อน เพื่อให้ event ที่ publish หลังจากนี้ไม่หาย
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe %s: %v", topic, err)
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := &Subscription{
+		pubsub:        pubsub,
+		handler:       handler,
+		onResubscribe: options.onResubscribe,
+		sem:           make(chan struct{}, options.concurrency),
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	go sub.receiveLoop(subCtx, ctx)
+
+	return sub, nil
+}
+
+// receiveLoop รับ message จน ctx ถูกยกเลิก ส่วน handler ใช้ handlerCtx
+// เพื่อให้ handler ที่กำลังทำงานตอน Close ทำต่อจนจบได้
+func (s *Subscription) receiveLoop(ctx context.Context, handlerCtx context.Context) {
+	defer close(s.done)
+
+	backoff := 100 * time.Millisecond
+	for {
+		received, err := s.pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil || err == rdb.ErrClosed {
+				return
+			}
+			log.Printf("Event subscription receive failed, retrying in %v: %v", backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 5*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = 100 * time.Millisecond
+
+		var msg *rdb.Message
+		switch m := received.(type) {
+		case *rdb.Message:
+			msg = m
+		case *rdb.Subscription:
+			// confirmation แรกถูกอ่านไปแล้วใน Subscribe ตัวที่มาทีหลังคือการ subscribe ใหม่หลัง reconnect
+			if (m.Kind == "subscribe" || m.Kind == "psubscribe") && s.onResubscribe != nil {
+				s.onResubscribe()
+			}
+			continue
+		default:
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			log.Printf("Failed to unmarshal event on %s: %v", msg.Channel, err)
+			continue
+		}
+
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Event handler for %s panicked: %v", event.Topic, r)
+				}
+				<-s.sem
+				s.wg.Done()
+			}()
+
+			if err := s.handler(handlerCtx, &event); err != nil {
+				log.Printf("Event handler for %s (id: %s) failed: %v", event.Topic, event.ID, err)
+			}
+		}()
+	}
+}
+
+// Close หยุดรับ event ใหม่แล้วรอ handler ที่กำลังทำงานอยู่จนเสร็จ
+func (s *Subscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		s.cancel()
+		err = s.pubsub.Close()
+		<-s.done
+		s.wg.Wait()
+	})
+	return err
+}
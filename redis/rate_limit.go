@@ -0,0 +1,311 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const rateLimitPrefix = "rate_limit:"
+
+type RateLimitAlgorithm string
+
+const (
+	// SlidingWindow นับ request จริงในหน้าต่างเวลา (sorted set) แม่นยำแต่ใช้ memory ตามจำนวน request
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+	// GCRA (generic cell rate algorithm) เก็บแค่ค่าเดียวต่อ key และรองรับ burst
+	GCRA RateLimitAlgorithm = "gcra"
+)
+
+// RateLimit กำหนดจำนวน request ที่อนุญาตต่อ Period
+// Burst ใช้กับ GCRA เท่านั้น ถ้าไม่กำหนดจะเท่ากับ Limit
+type RateLimit struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+func PerSecond(limit int) RateLimit {
+	return RateLimit{Limit: limit, Period: time.Second}
+}
+
+func PerMinute(limit int) RateLimit {
+	return RateLimit{Limit: limit, Period: time.Minute}
+}
+
+func PerHour(limit int) RateLimit {
+	return RateLimit{Limit: limit, Period: time.Hour}
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// ทั้งสอง script ใช้เวลาจาก Redis (TIME) เพื่อไม่ให้นาฬิกาของแต่ละ replica คลาดกัน
+var slidingWindowScript = rdb.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", key, 0, now - window)
+local count = redis.call("ZCARD", key)
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", key, now, member)
+	redis.call("PEXPIRE", key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+local reset_after = window
+if oldest[2] then
+	reset_after = tonumber(oldest[2]) + window - now
+end
+
+if allowed == 1 then
+	return {1, limit - count, -1, reset_after}
+end
+return {0, 0, reset_after, reset_after}
+`)
+
+var gcraScript = rdb.NewScript(`
+local key = KEYS[1]
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local emission = period / rate
+local burst_offset = emission * burst
+
+local tat = redis.call("GET", key)
+if not tat then
+	tat = now
+else
+	tat = math.max(tonumber(tat), now)
+end
+
+local new_tat = tat + emission
+local diff = now - (new_tat - burst_offset)
+if diff < 0 then
+	return {0, 0, math.ceil(-diff), math.ceil(tat - now)}
+end
+
+local reset_after = new_tat - now
+redis.call("SET", key, new_tat, "PX", math.ceil(reset_after))
+return {1, math.floor(diff / emission), -1, math.ceil(reset_after)}
+`)
+
+type RateLimiter struct {
+	client    *Client
+	algorithm RateLimitAlgorithm
+}
+
+func NewRateLimiter(client *Client, algorithm RateLimitAlgorithm) *RateLimiter {
+	if algorithm == "" {
+		algorithm = GCRA
+	}
+	return &RateLimiter{
+		client:    client,
+		algorithm: algorithm,
+	}
+}
+
+// Allow นับ request หนึ่งครั้งสำหรับ key แล้วบอกว่าอนุญาตหรือไม่
+func (rl *RateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error) {
+	if limit.Limit <= 0 || limit.Period <= 0 {
+		return nil, fmt.Errorf("invalid rate limit: %d per %v", limit.Limit, limit.Period)
+	}
+
+	var (
+		values []interface{}
+		err    error
+	)
+	switch rl.algorithm {
+	case SlidingWindow:
+		member, _ := uuid.NewV4()
+		values, err = slidingWindowScript.Run(ctx, rl.client.rdbc, []string{rateLimitPrefix + key},
+			limit.Limit, limit.Period.Milliseconds(), member.String()).Slice()
+	default:
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = limit.Limit
+		}
+		values, err = gcraScript.Run(ctx, rl.client.rdbc, []string{rateLimitPrefix + key},
+			burst, limit.Limit, limit.Period.Milliseconds()).Slice()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rate limit script: %v", err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit result: %v", values)
+	}
+
+	result := &RateLimitResult{
+		Allowed:    values[0].(int64) == 1,
+		Limit:      limit.Limit,
+		Remaining:  int(values[1].(int64)),
+		ResetAfter: time.Duration(values[3].(int64)) * time.Millisecond,
+	}
+	if retryAfter := values[2].(int64); retryAfter >= 0 {
+		result.RetryAfter = time.Duration(retryAfter) * time.Millisecond
+	}
+	return result, nil
+}
+
+// Reset ลบสถานะของ key ทำให้เริ่มนับใหม่
+func (rl *RateLimiter) Reset(ctx context.Context, key string) error {
+	return rl.client.rdbc.Del(ctx, rateLimitPrefix+key).Err()
+}
+
+// RateLimitKeyFunc คืน key ที่ใช้นับ request ถ้าคืน "" จะไม่จำกัด request นั้น
+type RateLimitKeyFunc func(c echo.Context) string
+
+// RateLimitByIP นับตาม IP ของ client (ใช้ echo.IPExtractor ที่ตั้งไว้)
+func RateLimitByIP() RateLimitKeyFunc {
+	return func(c echo.Context) string {
+		return "ip:" + c.RealIP()
+	}
+}
+
+// RateLimitByJWTSubject นับตาม claim "sub" ของ JWT payload ที่ middleware
+// ตรวจ token เก็บไว้ใน context key (เช่น "payload")
+func RateLimitByJWTSubject(contextKey string) RateLimitKeyFunc {
+	return func(c echo.Context) string {
+		var subject string
+		switch payload := c.Get(contextKey).(type) {
+		case map[string]interface{}:
+			subject, _ = payload["sub"].(string)
+		case interface{ GetSubject() (string, error) }:
+			subject, _ = payload.GetSubject()
+		}
+		if subject == "" {
+			return ""
+		}
+		return "sub:" + subject
+	}
+}
+
+// RateLimitByAPIKey นับตามค่าใน header เช่น "X-API-Key"
+func RateLimitByAPIKey(header string) RateLimitKeyFunc {
+	return func(c echo.Context) string {
+		apiKey := c.Request().Header.Get(header)
+		if apiKey == "" {
+			return ""
+		}
+		return "api_key:" + apiKey
+	}
+}
+
+// RateLimitByRoute แยกการนับตาม route โดยต่อท้าย key จาก keyFunc
+// ถ้า keyFunc เป็น nil จะนับรวมทุก client ของ route นั้น
+func RateLimitByRoute(keyFunc RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(c echo.Context) string {
+		route := "route:" + c.Request().Method + " " + c.Path()
+		if keyFunc == nil {
+			return route
+		}
+		key := keyFunc(c)
+		if key == "" {
+			return ""
+		}
+		return route + ":" + key
+	}
+}
+
+type RateLimitConfig struct {
+	Limiter *RateLimiter
+	// Limit ค่าเริ่มต้นสำหรับทุก route
+	Limit RateLimit
+	// RouteLimits override Limit ราย route โดยใช้ key แบบ "GET /users/:id" หรือแค่ "/users/:id"
+	RouteLimits map[string]RateLimit
+	// KeyFunc ค่าเริ่มต้นคือ RateLimitByIP
+	KeyFunc RateLimitKeyFunc
+	Skipper func(c echo.Context) bool
+	// FailClosed ตอบ 503 เมื่อ Redis ใช้งานไม่ได้ ค่าเริ่มต้นคือปล่อย request ผ่าน
+	FailClosed bool
+}
+
+// RateLimitMiddleware จำกัด request ด้วย RateLimiter และตอบ header
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset และ Retry-After เมื่อเกิน limit
+func RateLimitMiddleware(config RateLimitConfig) echo.MiddlewareFunc {
+	if config.KeyFunc == nil {
+		config.KeyFunc = RateLimitByIP()
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper != nil && config.Skipper(c) {
+				return next(c)
+			}
+
+			limit, route := config.limitFor(c)
+			if limit.Limit <= 0 {
+				return next(c)
+			}
+
+			key := config.KeyFunc(c)
+			if key == "" {
+				return next(c)
+			}
+			if route != "" {
+				// route ที่มี limit ของตัวเองต้องนับแยกจาก limit กลาง
+				key = route + ":" + key
+			}
+
+			result, err := config.Limiter.Allow(c.Request().Context(), key, limit)
+			if err != nil {
+				log.Printf("Rate limiter failed for key %s: %v", key, err)
+				if config.FailClosed {
+					return echo.NewHTTPError(http.StatusServiceUnavailable, "rate limiter unavailable")
+				}
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(durationToSeconds(result.ResetAfter)))
+
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(durationToSeconds(result.RetryAfter)))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "too many requests")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// limitFor คืน limit ของ request และชื่อ route ที่ match ใน RouteLimits (ถ้ามี)
+func (config RateLimitConfig) limitFor(c echo.Context) (RateLimit, string) {
+	for _, route := range []string{c.Request().Method + " " + c.Path(), c.Path()} {
+		if limit, exists := config.RouteLimits[route]; exists {
+			return limit, route
+		}
+	}
+	return config.Limit, ""
+}
+
+func durationToSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
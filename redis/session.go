@@ -0,0 +1,415 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultSessionTTL     = 24 * time.Hour
+	SessionContextKey     = "session"
+	sessionKeyPrefix      = "session:"
+	userSessionsKeyPrefix = "user_sessions:"
+	sessionCookieName     = "session_id"
+)
+
+// ErrSessionNotFound คือ session ที่จะ Save ไม่มีอยู่แล้ว เช่นถูก Revoke หรือหมดอายุไประหว่างทาง
+var ErrSessionNotFound = errors.New("redis: session not found")
+
+// sessionTouchScript เขียน session ที่ต่ออายุแล้วกลับลง key เดิมโดยคง TTL ที่ GETEX ตั้งไว้
+// เขียนเฉพาะเมื่อค่าใน Redis ยังเป็นค่าที่อ่านมา เพื่อไม่ทับ Save ที่เกิดขึ้นระหว่างทางและไม่ชุบชีวิต session ที่ถูก Revoke
+var sessionTouchScript = rdb.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "KEEPTTL")
+	return 1
+end
+return 0
+`)
+
+type Session struct {
+	ID         string                 `json:"id"`
+	UserID     string                 `json:"user_id"`
+	Data       map[string]interface{} `json:"data"`
+	IP         string                 `json:"ip,omitempty"`
+	UserAgent  string                 `json:"user_agent,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	LastSeenAt time.Time              `json:"last_seen_at"`
+	ExpiresAt  time.Time              `json:"expires_at"`
+}
+
+// SessionCookieManager คือส่วนของ cookie.CookieManager ที่ session middleware ใช้
+type SessionCookieManager interface {
+	GetSessionID(c echo.Context) (string, error)
+	SetSessionCookie(c echo.Context, sessionID string, expiresAt time.Time)
+	ClearCookie(c echo.Context, name, path string)
+}
+
+type SessionStore struct {
+	client *Client
+	ttl    time.Duration
+}
+
+// NewSessionStore สร้าง store ที่ต่ออายุ session ออกไปอีก ttl ทุกครั้งที่ถูกใช้งาน (sliding expiration)
+func NewSessionStore(client *Client, ttl time.Duration) *SessionStore {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &SessionStore{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create สร้าง session ใหม่ให้ user
+func (s *SessionStore) Create(ctx context.Context, userID string, data map[string]interface{}) (*Session, error) {
+	session, err := s.newSession(userID, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(ctx, session, false); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *SessionStore) newSession(userID string, data map[string]interface{}) (*Session, error) {
+	sessionID, err := newSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %v", err)
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:         sessionID,
+		UserID:     userID,
+		Data:       data,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+	}
+	if session.Data == nil {
+		session.Data = make(map[string]interface{})
+	}
+	return session, nil
+}
+
+// Get โหลด session และต่ออายุ คืน nil ถ้าไม่พบหรือหมดอายุแล้ว
+// ต่ออายุด้วย GETEX และ ZADD XX ซึ่งไม่สร้าง key ใหม่ จึงไม่ชุบชีวิต session ที่ถูก Revoke ระหว่างทาง
+// แล้วเขียน LastSeenAt/ExpiresAt ใหม่กลับลง Redis เพื่อให้ ListUserSessions เห็นเวลาหมดอายุจริง
+func (s *SessionStore) Get(ctx context.Context, sessionID string) (*Session, error) {
+	result, err := s.client.rdbc.GetEx(ctx, sessionKeyPrefix+sessionID, s.ttl).Bytes()
+	if err != nil {
+		if err == rdb.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session: %v", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(result, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %v", err)
+	}
+
+	now := time.Now()
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(s.ttl)
+
+	touched, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session: %v", err)
+	}
+	if err := sessionTouchScript.Run(ctx, s.client.rdbc, []string{sessionKeyPrefix + sessionID}, result, touched).Err(); err != nil {
+		log.Printf("Warning: failed to update session expiry: %v", err)
+	}
+
+	if session.UserID != "" {
+		userKey := userSessionsKeyPrefix + session.UserID
+		_, err := s.client.rdbc.Pipelined(ctx, func(pipe rdb.Pipeliner) error {
+			pipe.ZAddXX(ctx, userKey, rdb.Z{Score: float64(session.ExpiresAt.Unix()), Member: session.ID})
+			pipe.Expire(ctx, userKey, s.ttl)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Warning: failed to extend user session index: %v", err)
+		}
+	}
+
+	return &session, nil
+}
+
+// Save เขียน session ที่มีอยู่แล้วกลับลง Redis พร้อมต่ออายุตาม ExpiresAt
+// คืน ErrSessionNotFound ถ้า session ถูก Revoke หรือหมดอายุไปแล้ว เพื่อไม่ชุบชีวิต session นั้น
+func (s *SessionStore) Save(ctx context.Context, session *Session) error {
+	return s.save(ctx, session, true)
+}
+
+// save เขียน session ลง Redis ถ้า existing เป็น true จะเขียนเฉพาะเมื่อ key ยังอยู่ (SET XX)
+func (s *SessionStore) save(ctx context.Context, session *Session, existing bool) error {
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %v", err)
+	}
+
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return s.Revoke(ctx, session.ID)
+	}
+
+	if existing {
+		saved, err := s.client.rdbc.SetXX(ctx, sessionKeyPrefix+session.ID, sessionJSON, ttl).Result()
+		if err != nil {
+			return fmt.Errorf("failed to save session: %v", err)
+		}
+		if !saved {
+			return ErrSessionNotFound
+		}
+	}
+
+	// ใช้ pipeline ธรรมดาแทน MULTI เพราะ key ทั้งสองอาจอยู่คนละ slot ใน Redis Cluster
+	_, err = s.client.rdbc.Pipelined(ctx, func(pipe rdb.Pipeliner) error {
+		if !existing {
+			pipe.Set(ctx, sessionKeyPrefix+session.ID, sessionJSON, ttl)
+		}
+		if session.UserID != "" {
+			userKey := userSessionsKeyPrefix + session.UserID
+			pipe.ZAdd(ctx, userKey, rdb.Z{Score: float64(session.ExpiresAt.Unix()), Member: session.ID})
+			pipe.ZRemRangeByScore(ctx, userKey, "-inf", fmt.Sprintf("%d", time.Now().Unix()))
+			// ทุก session ใช้ ttl เท่ากัน ตัวที่เพิ่ง save จึงหมดอายุช้าที่สุดเสมอ
+			pipe.Expire(ctx, userKey, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save session: %v", err)
+	}
+	return nil
+}
+
+// Rotate ออก session ID ใหม่โดยคงข้อมูลเดิม แล้วลบ ID เก่า
+// ควรเรียกทุกครั้งที่สิทธิ์ของ session เปลี่ยน เช่นตอน login เพื่อกัน session fixation
+func (s *SessionStore) Rotate(ctx context.Context, session *Session) (*Session, error) {
+	oldID := session.ID
+	newID, err := newSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %v", err)
+	}
+
+	rotated := *session
+	rotated.ID = newID
+	rotated.LastSeenAt = time.Now()
+	rotated.ExpiresAt = rotated.LastSeenAt.Add(s.ttl)
+
+	if err := s.save(ctx, &rotated, false); err != nil {
+		return nil, err
+	}
+	if err := s.Revoke(ctx, oldID); err != nil {
+		log.Printf("Warning: failed to revoke rotated session: %v", err)
+	}
+	return &rotated, nil
+}
+
+// Revoke ลบ session เดียว
+func (s *SessionStore) Revoke(ctx context.Context, sessionID string) error {
+	result, err := s.client.rdbc.GetDel(ctx, sessionKeyPrefix+sessionID).Bytes()
+	if err != nil {
+		if err == rdb.Nil {
+			return nil
+		}
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(result, &session); err == nil && session.UserID != "" {
+		if err := s.client.rdbc.ZRem(ctx, userSessionsKeyPrefix+session.UserID, sessionID).Err(); err != nil {
+			log.Printf("Warning: failed to remove session from user index: %v", err)
+		}
+	}
+	return nil
+}
+
+// RevokeAllForUser ลบทุก session ของ user (log out everywhere)
+func (s *SessionStore) RevokeAllForUser(ctx context.Context, userID string) error {
+	userKey := userSessionsKeyPrefix + userID
+	sessionIDs, err := s.client.rdbc.ZRange(ctx, userKey, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list user sessions: %v", err)
+	}
+
+	_, err = s.client.rdbc.Pipelined(ctx, func(pipe rdb.Pipeliner) error {
+		for _, sessionID := range sessionIDs {
+			pipe.Del(ctx, sessionKeyPrefix+sessionID)
+		}
+		pipe.Del(ctx, userKey)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %v", err)
+	}
+	return nil
+}
+
+// ListUserSessions คืน session ที่ยังไม่หมดอายุของ user เรียงจากเก่าไปใหม่
+func (s *SessionStore) ListUserSessions(ctx context.Context, userID string) ([]*Session, error) {
+	userKey := userSessionsKeyPrefix + userID
+	now := fmt.Sprintf("%d", time.Now().Unix())
+	if err := s.client.rdbc.ZRemRangeByScore(ctx, userKey, "-inf", now).Err(); err != nil {
+		return nil, fmt.Errorf("failed to prune user sessions: %v", err)
+	}
+
+	sessionIDs, err := s.client.rdbc.ZRange(ctx, userKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user sessions: %v", err)
+	}
+	if len(sessionIDs) == 0 {
+		return []*Session{}, nil
+	}
+
+	cmds := make([]*rdb.StringCmd, len(sessionIDs))
+	_, err = s.client.rdbc.Pipelined(ctx, func(pipe rdb.Pipeliner) error {
+		for i, sessionID := range sessionIDs {
+			cmds[i] = pipe.Get(ctx, sessionKeyPrefix+sessionID)
+		}
+		return nil
+	})
+	if err != nil && err != rdb.Nil {
+		return nil, fmt.Errorf("failed to load user sessions: %v", err)
+	}
+
+	sessions := make([]*Session, 0, len(sessionIDs))
+	for _, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			log.Printf("Failed to unmarshal session: %v", err)
+			continue
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, nil
+}
+
+// Login สร้าง session ใหม่ให้ userID (หรือ rotate session เดิมของ request) แล้วตั้ง cookie
+func (s *SessionStore) Login(c echo.Context, cookies SessionCookieManager, userID string, data map[string]interface{}) (*Session, error) {
+	ctx := c.Request().Context()
+
+	var (
+		session *Session
+		err     error
+	)
+	current := GetSession(c)
+	if current != nil && current.UserID != "" && current.UserID != userID {
+		// session ของ user อื่นค้างอยู่ใน browser ห้ามส่งต่อข้อมูลให้ user ใหม่
+		if err := s.Revoke(ctx, current.ID); err != nil {
+			return nil, err
+		}
+		current = nil
+	}
+
+	if current != nil {
+		// session ที่ยังไม่ login หรือของ user เดิม คงข้อมูลไว้แต่ออก ID ใหม่
+		rotated := *current
+		rotated.UserID = userID
+		rotated.Data = make(map[string]interface{}, len(current.Data)+len(data))
+		for k, v := range current.Data {
+			rotated.Data[k] = v
+		}
+		for k, v := range data {
+			rotated.Data[k] = v
+		}
+		rotated.IP = c.RealIP()
+		rotated.UserAgent = c.Request().UserAgent()
+		// Revoke ID เก่าใช้ UserID ที่เก็บอยู่ใน Redis จึงลบออกจาก index ของ user เดิมได้ถูกต้อง
+		session, err = s.Rotate(ctx, &rotated)
+	} else {
+		session, err = s.newSession(userID, data)
+		if err == nil {
+			session.IP = c.RealIP()
+			session.UserAgent = c.Request().UserAgent()
+			err = s.save(ctx, session, false)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cookies.SetSessionCookie(c, session.ID, session.ExpiresAt)
+	c.Set(SessionContextKey, session)
+	return session, nil
+}
+
+// Logout ลบ session ของ request และ cookie
+func (s *SessionStore) Logout(c echo.Context, cookies SessionCookieManager) error {
+	if session := GetSession(c); session != nil {
+		if err := s.Revoke(c.Request().Context(), session.ID); err != nil {
+			return err
+		}
+	}
+	cookies.ClearCookie(c, sessionCookieName, "/")
+	c.Set(SessionContextKey, nil)
+	return nil
+}
+
+// GetSession คืน session ที่ SessionMiddleware โหลดไว้ หรือ nil
+func GetSession(c echo.Context) *Session {
+	session, _ := c.Get(SessionContextKey).(*Session)
+	return session
+}
+
+// SessionMiddleware โหลด session จาก cookie session_id ลงใน context (ดู GetSession)
+// และต่ออายุ cookie ตาม sliding expiration ถ้า session ไม่มีอยู่จะลบ cookie ทิ้ง
+func SessionMiddleware(store *SessionStore, cookies SessionCookieManager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			sessionID, err := cookies.GetSessionID(c)
+			if err != nil || sessionID == "" {
+				return next(c)
+			}
+
+			session, err := store.Get(c.Request().Context(), sessionID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+			if session == nil {
+				cookies.ClearCookie(c, sessionCookieName, "/")
+				return next(c)
+			}
+
+			cookies.SetSessionCookie(c, session.ID, session.ExpiresAt)
+			c.Set(SessionContextKey, session)
+			return next(c)
+		}
+	}
+}
+
+// RequireSession ตอบ 401 ถ้า request ไม่มี session ต้องใช้หลัง SessionMiddleware
+func RequireSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if GetSession(c) == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "session is required")
+			}
+			return next(c)
+		}
+	}
+}
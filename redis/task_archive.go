@@ -0,0 +1,315 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/GodeFvt/go-backend/helper/models"
+	"github.com/gofrs/uuid"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	TaskArchiveKey         = "task_archive"
+	DefaultArchiveMaxCount = 100000
+	DefaultArchiveMaxAge   = 7 * 24 * time.Hour
+	archiveDayLayout       = "2006-01-02"
+	archiveSearchTTL       = 30 * time.Second
+	// archiveRecordGrace ให้ record อยู่นานกว่า MaxAge เพื่อให้ trimArchive ยังอ่าน type/status
+	// ของ record ได้ตอนลบออกจาก index ถ้า record หมดอายุก่อน index จะค้างตลอดไป
+	archiveRecordGrace = 24 * time.Hour
+)
+
+type ArchiveConfig struct {
+	// MaxCount จำนวน record สูงสุดที่เก็บไว้ record ที่เก่าที่สุดจะถูกลบก่อน
+	MaxCount int64
+	// MaxAge อายุสูงสุดของ record
+	MaxAge time.Duration
+}
+
+// TaskRecord คือประวัติของ task ที่ทำงานจบแล้ว (สำเร็จหรือล้มเหลวถาวร)
+// ไม่เก็บ payload เพื่อไม่ให้ข้อมูลส่วนบุคคลค้างอยู่ใน archive
+type TaskRecord struct {
+	ID         string        `json:"id"`
+	Type       TaskType      `json:"type"`
+	Status     TaskStatus    `json:"status"`
+	Attempts   int           `json:"attempts"`
+	WorkerID   string        `json:"worker_id,omitempty"`
+	ErrorMsg   string        `json:"error_msg,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	CreatedAt  time.Time     `json:"created_at"`
+	StartedAt  *time.Time    `json:"started_at,omitempty"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+// TaskSearchFilter เงื่อนไขของ SearchTasks ทุก field เป็น optional
+type TaskSearchFilter struct {
+	Type   TaskType
+	Status TaskStatus
+	// Day เลือกเฉพาะ task ที่จบในวันนั้น โดยนับวันตามเวลา UTC
+	Day time.Time
+	// From และ To จำกัดช่วงเวลาที่ task จบ
+	From time.Time
+	To   time.Time
+}
+
+// WithArchive เก็บประวัติ task ที่จบแล้วไว้ค้นหาด้วย SearchTasks
+func WithArchive(config ArchiveConfig) TaskQueueOption {
+	return func(tq *TaskQueue) {
+		if config.MaxCount <= 0 {
+			config.MaxCount = DefaultArchiveMaxCount
+		}
+		if config.MaxAge <= 0 {
+			config.MaxAge = DefaultArchiveMaxAge
+		}
+		tq.archive = &config
+	}
+}
+
+func (tq *TaskQueue) archiveRecordKey(taskID string) string {
+	return tq.key(TaskArchiveKey + ":" + taskID)
+}
+
+func (tq *TaskQueue) archiveIndexKey() string {
+	return tq.key(TaskArchiveKey + ":index")
+}
+
+func (tq *TaskQueue) archiveTypeKey(taskType TaskType) string {
+	return tq.key(TaskArchiveKey + ":type:" + taskType.String())
+}
+
+func (tq *TaskQueue) archiveStatusKey(status TaskStatus) string {
+	return tq.key(TaskArchiveKey + ":status:" + string(status))
+}
+
+func (tq *TaskQueue) archiveDayKey(day string) string {
+	return tq.key(TaskArchiveKey + ":day:" + day)
+}
+
+// archiveTask บันทึก task ที่จบแล้วลง archive ถ้าเปิดใช้ ไม่คืน error เพื่อไม่ให้กระทบการทำงานหลัก
+func (tq *TaskQueue) archiveTask(ctx context.Context, task *Task) {
+	if tq.archive == nil {
+		return
+	}
+
+	finishedAt := time.Now()
+	record := TaskRecord{
+		ID:         task.ID,
+		Type:       task.Type,
+		Status:     task.Status,
+		Attempts:   task.RetryCount + 1,
+		WorkerID:   task.WorkerID,
+		ErrorMsg:   task.ErrorMsg,
+		CreatedAt:  task.CreatedAt,
+		StartedAt:  task.ProcessedAt,
+		FinishedAt: finishedAt,
+	}
+	if task.Status == TaskStatusFailed {
+		record.Attempts = task.RetryCount
+	}
+	if task.ProcessedAt != nil {
+		record.Duration = finishedAt.Sub(*task.ProcessedAt)
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Warning: failed to marshal task record %s: %v", task.ID, err)
+		return
+	}
+
+	score := float64(finishedAt.UnixMilli())
+	member := rdb.Z{Score: score, Member: task.ID}
+	dayKey := tq.archiveDayKey(finishedAt.UTC().Format(archiveDayLayout))
+
+	_, err = tq.client.rdbc.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+		pipe.Set(ctx, tq.archiveRecordKey(task.ID), recordJSON, tq.archive.MaxAge+archiveRecordGrace)
+		pipe.ZAdd(ctx, tq.archiveIndexKey(), member)
+		pipe.ZAdd(ctx, tq.archiveTypeKey(task.Type), member)
+		pipe.ZAdd(ctx, tq.archiveStatusKey(task.Status), member)
+		pipe.ZAdd(ctx, dayKey, member)
+		pipe.Expire(ctx, dayKey, tq.archive.MaxAge+24*time.Hour)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: failed to archive task %s: %v", task.ID, err)
+		return
+	}
+
+	if err := tq.trimArchive(ctx); err != nil {
+		log.Printf("Warning: failed to trim task archive: %v", err)
+	}
+}
+
+// trimArchive ลบ record ที่เก่าเกิน MaxAge หรือเกิน MaxCount ออกจาก archive และทุก index
+func (tq *TaskQueue) trimArchive(ctx context.Context) error {
+	indexKey := tq.archiveIndexKey()
+	cutoff := strconv.FormatInt(time.Now().Add(-tq.archive.MaxAge).UnixMilli(), 10)
+
+	expiredIDs, err := tq.client.rdbc.ZRangeByScore(ctx, indexKey, &rdb.ZRangeBy{Min: "-inf", Max: "(" + cutoff}).Result()
+	if err != nil {
+		return err
+	}
+
+	count, err := tq.client.rdbc.ZCard(ctx, indexKey).Result()
+	if err != nil {
+		return err
+	}
+	if excess := count - int64(len(expiredIDs)) - tq.archive.MaxCount; excess > 0 {
+		oldestIDs, err := tq.client.rdbc.ZRange(ctx, indexKey, int64(len(expiredIDs)), int64(len(expiredIDs))+excess-1).Result()
+		if err != nil {
+			return err
+		}
+		expiredIDs = append(expiredIDs, oldestIDs...)
+	}
+	if len(expiredIDs) == 0 {
+		return nil
+	}
+
+	records, err := tq.loadTaskRecords(ctx, expiredIDs)
+	if err != nil {
+		return err
+	}
+
+	members := make([]interface{}, len(expiredIDs))
+	for i, id := range expiredIDs {
+		members[i] = id
+	}
+
+	_, err = tq.client.rdbc.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+		pipe.ZRem(ctx, indexKey, members...)
+		for _, record := range records {
+			pipe.ZRem(ctx, tq.archiveTypeKey(record.Type), record.ID)
+			pipe.ZRem(ctx, tq.archiveStatusKey(record.Status), record.ID)
+			pipe.ZRem(ctx, tq.archiveDayKey(record.FinishedAt.UTC().Format(archiveDayLayout)), record.ID)
+		}
+		for _, id := range expiredIDs {
+			pipe.Del(ctx, tq.archiveRecordKey(id))
+		}
+		return nil
+	})
+	return err
+}
+
+// loadTaskRecords โหลด record ตามลำดับ id ที่ส่งมา record ที่หมดอายุไปแล้วจะถูกข้าม
+func (tq *TaskQueue) loadTaskRecords(ctx context.Context, taskIDs []string) ([]*TaskRecord, error) {
+	if len(taskIDs) == 0 {
+		return []*TaskRecord{}, nil
+	}
+
+	keys := make([]string, len(taskIDs))
+	for i, id := range taskIDs {
+		keys[i] = tq.archiveRecordKey(id)
+	}
+
+	values, err := tq.client.rdbc.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load task records: %v", err)
+	}
+
+	records := make([]*TaskRecord, 0, len(values))
+	for _, value := range values {
+		recordJSON, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var record TaskRecord
+		if err := json.Unmarshal([]byte(recordJSON), &record); err != nil {
+			log.Printf("Failed to unmarshal task record: %v", err)
+			continue
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// GetArchivedTask ดู record ของ task ที่จบแล้ว คืน nil ถ้าไม่พบ
+func (tq *TaskQueue) GetArchivedTask(ctx context.Context, taskID string) (*TaskRecord, error) {
+	records, err := tq.loadTaskRecords(ctx, []string{taskID})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// SearchTasks ค้นหา task ที่จบแล้วจาก archive เรียงจากจบล่าสุดก่อน
+// และคืน page ที่ตั้งค่า TotalEntrySizes/TotalPages แล้ว
+func (tq *TaskQueue) SearchTasks(ctx context.Context, filter TaskSearchFilter, page models.Paginator) ([]*TaskRecord, models.Paginator, error) {
+	if tq.archive == nil {
+		return nil, page, fmt.Errorf("task archive is not enabled")
+	}
+	if page.Page <= 0 {
+		page.Page = 1
+	}
+	if page.PerPage <= 0 {
+		page.PerPage = models.NewPaginator().PerPage
+	}
+
+	indexKeys := []string{}
+	if filter.Type != "" {
+		indexKeys = append(indexKeys, tq.archiveTypeKey(filter.Type))
+	}
+	if filter.Status != "" {
+		indexKeys = append(indexKeys, tq.archiveStatusKey(filter.Status))
+	}
+	if !filter.Day.IsZero() {
+		indexKeys = append(indexKeys, tq.archiveDayKey(filter.Day.UTC().Format(archiveDayLayout)))
+	}
+
+	searchKey := tq.archiveIndexKey()
+	if len(indexKeys) == 1 {
+		searchKey = indexKeys[0]
+	} else if len(indexKeys) > 1 {
+		// รวม index ลง key ชั่วคราว โดยคงคะแนน (เวลาที่จบ) ของ index แรก
+		searchID, _ := uuid.NewV4()
+		searchKey = tq.key(TaskArchiveKey + ":search:" + searchID.String())
+		weights := make([]float64, len(indexKeys))
+		weights[0] = 1
+
+		_, err := tq.client.rdbc.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+			pipe.ZInterStore(ctx, searchKey, &rdb.ZStore{Keys: indexKeys, Weights: weights, Aggregate: "SUM"})
+			pipe.Expire(ctx, searchKey, archiveSearchTTL)
+			return nil
+		})
+		if err != nil {
+			return nil, page, fmt.Errorf("failed to search task archive: %v", err)
+		}
+		defer tq.client.rdbc.Del(ctx, searchKey)
+	}
+
+	min, max := "-inf", "+inf"
+	if !filter.From.IsZero() {
+		min = strconv.FormatInt(filter.From.UnixMilli(), 10)
+	}
+	if !filter.To.IsZero() {
+		max = strconv.FormatInt(filter.To.UnixMilli(), 10)
+	}
+
+	total, err := tq.client.rdbc.ZCount(ctx, searchKey, min, max).Result()
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to count task archive: %v", err)
+	}
+	page.SetPaginatorByAllRows(int(total))
+
+	taskIDs, err := tq.client.rdbc.ZRevRangeByScore(ctx, searchKey, &rdb.ZRangeBy{
+		Min:    min,
+		Max:    max,
+		Offset: int64(page.GetOffset()),
+		Count:  int64(page.GetLimit()),
+	}).Result()
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to search task archive: %v", err)
+	}
+
+	records, err := tq.loadTaskRecords(ctx, taskIDs)
+	if err != nil {
+		return nil, page, err
+	}
+	return records, page, nil
+}
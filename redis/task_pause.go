@@ -0,0 +1,232 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	TaskPausedTypesKey          = "task_paused_types"
+	TaskQueuePausedKey          = "task_queue_paused"
+	TaskParkedQueuePrefix       = "task_parked:"
+	DefaultPauseRefreshInterval = 2 * time.Second
+)
+
+// PauseTaskType หยุดประมวลผล task type ชั่วคราว task ที่ค้างอยู่จะยังอยู่ใน queue
+// และถูกพักไว้ใน list ของ type นั้นเมื่อ worker dequeue ขึ้นมา
+// ทุก TaskWorker จะเห็นการเปลี่ยนแปลงภายใน DefaultPauseRefreshInterval
+func (tq *TaskQueue) PauseTaskType(ctx context.Context, taskType TaskType) error {
+	err := tq.client.rdbc.SAdd(ctx, tq.key(TaskPausedTypesKey), taskType.String()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to pause task type %s: %v", taskType, err)
+	}
+	return nil
+}
+
+// ResumeTaskType กลับมาประมวลผล task type ที่ถูก pause และย้าย task ที่ถูกพักไว้กลับเข้า queue
+func (tq *TaskQueue) ResumeTaskType(ctx context.Context, taskType TaskType) error {
+	err := tq.client.rdbc.SRem(ctx, tq.key(TaskPausedTypesKey), taskType.String()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to resume task type %s: %v", taskType, err)
+	}
+	if err := tq.unparkTasks(ctx, taskType); err != nil {
+		return fmt.Errorf("failed to move parked tasks of type %s back to queue: %v", taskType, err)
+	}
+	return nil
+}
+
+// PauseQueue หยุดการ dequeue ของทุก worker โดยไม่หยุด worker
+func (tq *TaskQueue) PauseQueue(ctx context.Context) error {
+	err := tq.client.rdbc.Set(ctx, tq.key(TaskQueuePausedKey), time.Now().Format(time.RFC3339), 0).Err()
+	if err != nil {
+		return fmt.Errorf("failed to pause queue: %v", err)
+	}
+	return nil
+}
+
+// ResumeQueue กลับมา dequeue ตามปกติ
+func (tq *TaskQueue) ResumeQueue(ctx context.Context) error {
+	err := tq.client.rdbc.Del(ctx, tq.key(TaskQueuePausedKey)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to resume queue: %v", err)
+	}
+	return nil
+}
+
+// GetPausedTaskTypes ดู task type ที่ถูก pause อยู่
+func (tq *TaskQueue) GetPausedTaskTypes(ctx context.Context) ([]TaskType, error) {
+	members, err := tq.client.rdbc.SMembers(ctx, tq.key(TaskPausedTypesKey)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paused task types: %v", err)
+	}
+
+	taskTypes := make([]TaskType, 0, len(members))
+	for _, member := range members {
+		taskTypes = append(taskTypes, TaskType(member))
+	}
+	return taskTypes, nil
+}
+
+// GetParkedTaskCounts คืนจำนวน task ที่พักไว้ของแต่ละ type ที่ถูก pause อยู่
+func (tq *TaskQueue) GetParkedTaskCounts(ctx context.Context) (map[TaskType]int64, error) {
+	taskTypes, err := tq.GetPausedTaskTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make(map[TaskType]*rdb.IntCmd, len(taskTypes))
+	_, err = tq.client.rdbc.Pipelined(ctx, func(pipe rdb.Pipeliner) error {
+		for _, taskType := range taskTypes {
+			cmds[taskType] = pipe.LLen(ctx, tq.parkedKey(taskType))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parked task counts: %v", err)
+	}
+
+	counts := make(map[TaskType]int64, len(cmds))
+	for taskType, cmd := range cmds {
+		counts[taskType] = cmd.Val()
+	}
+	return counts, nil
+}
+
+// IsQueuePaused ดูว่า queue ถูก pause ทั้งหมดอยู่หรือไม่
+func (tq *TaskQueue) IsQueuePaused(ctx context.Context) (bool, error) {
+	exists, err := tq.client.rdbc.Exists(ctx, tq.key(TaskQueuePausedKey)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to get queue pause state: %v", err)
+	}
+	return exists > 0, nil
+}
+
+// RequeueTask คืน task ที่ dequeue มาแล้วกลับไปท้าย queue ในสถานะ pending
+// โดยไม่นับเป็น retry ใช้เมื่อพัก task ที่ถูก pause ไม่สำเร็จ
+func (tq *TaskQueue) RequeueTask(ctx context.Context, task *Task) error {
+	task.Status = TaskStatusPending
+	task.UpdatedAt = time.Now()
+	task.ProcessedAt = nil
+
+	taskJSON, err := tq.encodeTask(task)
+	if err != nil {
+		return err
+	}
+
+	// ย้ายจาก processing กลับเข้า queue ใน transaction เดียว task จะได้ไม่หายระหว่างทาง
+	_, err = tq.client.rdbc.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+		if task.raw != "" {
+			pipe.LRem(ctx, tq.key(TaskProcessingKey), 1, task.raw)
+		}
+		if err := tq.pushPending(ctx, pipe, task, taskJSON); err != nil {
+			return err
+		}
+		pipe.HSet(ctx, tq.taskKey(task.ID), task.ID, taskJSON)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to requeue task: %v", err)
+	}
+	tq.releaseTenantSlot(ctx, task)
+
+	task.raw = ""
+	return nil
+}
+
+// errTaskTypeNotPaused บอกว่า task type ถูก resume ไปแล้วระหว่างที่ worker กำลังจะพัก task
+var errTaskTypeNotPaused = errors.New("task type is not paused")
+
+// parkedKey คืนชื่อ list ที่พัก task ของ type ที่ถูก pause
+func (tq *TaskQueue) parkedKey(taskType TaskType) string {
+	return tq.key(TaskParkedQueuePrefix + taskType.String())
+}
+
+// ParkTask ย้าย task ที่ dequeue มาแล้วไปพักใน list ของ type ที่ถูก pause จนกว่าจะถูก resume
+// คืนค่า false ถ้า type ถูก resume ไปแล้ว ซึ่ง worker ควรประมวลผล task ต่อตามปกติ
+func (tq *TaskQueue) ParkTask(ctx context.Context, task *Task) (bool, error) {
+	task.Status = TaskStatusPending
+	task.UpdatedAt = time.Now()
+	task.ProcessedAt = nil
+
+	taskJSON, err := tq.encodeTask(task)
+	if err != nil {
+		return false, err
+	}
+
+	// WATCH set ของ type ที่ถูก pause เพื่อไม่ให้พัก task หลังจาก ResumeTaskType ย้าย list กลับไปแล้ว
+	pausedKey := tq.key(TaskPausedTypesKey)
+	err = tq.client.rdbc.Watch(ctx, func(tx *rdb.Tx) error {
+		paused, err := tx.SIsMember(ctx, pausedKey, task.Type.String()).Result()
+		if err != nil {
+			return err
+		}
+		if !paused {
+			return errTaskTypeNotPaused
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+			if task.raw != "" {
+				pipe.LRem(ctx, tq.key(TaskProcessingKey), 1, task.raw)
+			}
+			pipe.LPush(ctx, tq.parkedKey(task.Type), taskJSON)
+			pipe.HSet(ctx, tq.taskKey(task.ID), task.ID, taskJSON)
+			return nil
+		})
+		return err
+	}, pausedKey)
+	if err == errTaskTypeNotPaused {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to park task: %v", err)
+	}
+	tq.releaseTenantSlot(ctx, task)
+
+	task.raw = ""
+	return true, nil
+}
+
+// unparkTasks ย้าย task ที่ถูกพักไว้กลับเข้า queue ตามลำดับเดิมทีละตัว
+// ใช้ WATCH เพื่อไม่ให้ task ถูกย้ายซ้ำเมื่อมีการ resume พร้อมกันหลายที่
+func (tq *TaskQueue) unparkTasks(ctx context.Context, taskType TaskType) error {
+	parkedKey := tq.parkedKey(taskType)
+	for {
+		moved := false
+		err := tq.client.rdbc.Watch(ctx, func(tx *rdb.Tx) error {
+			raw, err := tx.LIndex(ctx, parkedKey, -1).Result()
+			if err == rdb.Nil {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			// ใช้แค่ Type กับ TenantID เพื่อเลือก queue จึงไม่ต้องถอด payload
+			var task Task
+			if err := json.Unmarshal([]byte(raw), &task); err != nil {
+				task = Task{}
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+				pipe.RPop(ctx, parkedKey)
+				return tq.pushPending(ctx, pipe, &task, []byte(raw))
+			})
+			moved = err == nil
+			return err
+		}, parkedKey)
+		if err == rdb.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !moved {
+			return nil
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -18,6 +19,9 @@ const (
 	TaskFailedKey     = "task_failed"
 	DefaultRetryLimit = 3
 	DefaultTimeout    = 30 * time.Second
+
+	// taskHashTag ทำให้ key ของ task queue อยู่ slot เดียวกันเมื่อใช้ Redis Cluster
+	taskHashTag = "task"
 )
 
 type TaskType string
@@ -54,24 +58,65 @@ type Task struct {
 	ProcessedAt *time.Time             `json:"processed_at,omitempty"`
 	FailedAt    *time.Time             `json:"failed_at,omitempty"`
 	ErrorMsg    string                 `json:"error_msg,omitempty"`
+
+	// EncodedPayload เก็บ Payload ที่ผ่าน PayloadCodec แล้ว ใช้เฉพาะตอนอยู่ใน Redis
+	EncodedPayload []byte `json:"encoded_payload,omitempty"`
+	// PayloadRef คือ key ของ payload ใน BlobStore เมื่อ payload ใหญ่เกิน claim-check threshold
+	PayloadRef string `json:"payload_ref,omitempty"`
+	// WorkerID คือ TaskWorker ที่กำลังประมวลผล task นี้
+	WorkerID string `json:"worker_id,omitempty"`
+	// TenantID ใช้กับ fair scheduling ข้าม tenant (ดู WithTenantFairness)
+	TenantID string `json:"tenant_id,omitempty"`
+
+	// raw คือ JSON ที่อยู่ใน processing list ใช้กับ LREM ให้ลบได้ตรงตัว
+	raw string
 }
 
 type TaskQueue struct {
-	client *Client
+	client       *Client
+	payloadCodec PayloadCodec
+	blobStore    BlobStore
+	blobMinSize  int
+	archive      *ArchiveConfig
+
+	tenantFair          bool
+	tenantMaxConcurrent int
+	// fairTurn สลับว่า dequeueFair จะดึงจาก tenant หรือ queue หลักก่อน
+	fairTurn atomic.Uint64
 }
 
-func NewTaskQueue(client *Client) *TaskQueue {
-	return &TaskQueue{
+// TaskQueueOption ปรับการทำงานของ TaskQueue
+type TaskQueueOption func(*TaskQueue)
+
+// WithPayloadCodec บีบอัด/เข้ารหัส payload ของ task ก่อนเก็บลง Redis
+// task ที่ไม่ได้ encode (สร้างก่อนเปิดใช้) ยังอ่านได้ตามปกติ
+func WithPayloadCodec(codec PayloadCodec) TaskQueueOption {
+	return func(tq *TaskQueue) {
+		tq.payloadCodec = codec
+	}
+}
+
+func NewTaskQueue(client *Client, opts ...TaskQueueOption) *TaskQueue {
+	tq := &TaskQueue{
 		client: client,
 	}
+	for _, opt := range opts {
+		opt(tq)
+	}
+	return tq
 }
 
 // EnqueueTask เพิ่ม task ใหม่เข้า queue
 func (tq *TaskQueue) EnqueueTask(ctx context.Context, taskType TaskType, payload map[string]interface{}) (*Task, error) {
+	return tq.enqueueTask(ctx, "", taskType, payload)
+}
+
+func (tq *TaskQueue) enqueueTask(ctx context.Context, tenantID string, taskType TaskType, payload map[string]interface{}) (*Task, error) {
 	taskID, _ := uuid.NewV4()
 	task := &Task{
 		ID:         taskID.String(),
 		Type:       taskType,
+		TenantID:   tenantID,
 		Status:     TaskStatusPending,
 		Payload:    payload,
 		RetryCount: 0,
@@ -80,43 +125,72 @@ func (tq *TaskQueue) EnqueueTask(ctx context.Context, taskType TaskType, payload
 		UpdatedAt:  time.Now(),
 	}
 
-	taskJSON, err := json.Marshal(task)
+	if err := tq.enqueue(ctx, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// enqueue เพิ่ม task ที่สร้างไว้แล้วเข้า queue ใช้ร่วมกับ outbox relay ที่กำหนด task id เอง
+func (tq *TaskQueue) enqueue(ctx context.Context, task *Task) error {
+	if err := tq.offloadPayload(ctx, task); err != nil {
+		return err
+	}
+
+	taskJSON, err := tq.encodeTask(task)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal task: %v", err)
+		tq.deletePayloadBlob(ctx, task)
+		return err
 	}
 
 	// เพิ่ม task เข้า queue
-	err = tq.client.rdbc.LPush(ctx, TaskQueueKey, taskJSON).Err()
+	err = tq.pushPending(ctx, tq.client.rdbc, task, taskJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to enqueue task: %v", err)
+		// task ไม่ได้เข้า queue จึงไม่มีใครใช้ blob นี้
+		tq.deletePayloadBlob(ctx, task)
+		return fmt.Errorf("failed to enqueue task: %v", err)
 	}
 
 	// เก็บ task detail ใน hash
-	err = tq.client.rdbc.HSet(ctx, fmt.Sprintf("task:%s", task.ID), task.ID, taskJSON).Err()
+	err = tq.client.rdbc.HSet(ctx, tq.taskKey(task.ID), task.ID, taskJSON).Err()
 	if err != nil {
-		return nil, fmt.Errorf("failed to store task details: %v", err)
+		return fmt.Errorf("failed to store task details: %v", err)
 	}
 
 	log.Printf("Task %s enqueued successfully", task.ID)
-	return task, nil
+	return nil
 }
 
 // DequeueTask ดึง task จาก queue มาประมวลผล
 func (tq *TaskQueue) DequeueTask(ctx context.Context, timeout time.Duration) (*Task, error) {
-	// ใช้ BRPOPLPUSH เพื่อย้าย task จาก queue ไป processing list อย่างปลอดภัย
-	result := tq.client.rdbc.BRPopLPush(ctx, TaskQueueKey, TaskProcessingKey, timeout)
-	if result.Err() != nil {
-		if result.Err() == rdb.Nil {
+	var raw string
+	if tq.tenantFair {
+		var err error
+		raw, err = tq.dequeueFair(ctx, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dequeue task: %v", err)
+		}
+		if raw == "" {
 			return nil, nil // ไม่มี task
 		}
-		return nil, fmt.Errorf("failed to dequeue task: %v", result.Err())
+	} else {
+		// ใช้ BRPOPLPUSH เพื่อย้าย task จาก queue ไป processing list อย่างปลอดภัย
+		result := tq.client.rdbc.BRPopLPush(ctx, tq.key(TaskQueueKey), tq.key(TaskProcessingKey), timeout)
+		if result.Err() != nil {
+			if result.Err() == rdb.Nil {
+				return nil, nil // ไม่มี task
+			}
+			return nil, fmt.Errorf("failed to dequeue task: %v", result.Err())
+		}
+		raw = result.Val()
 	}
 
-	var task Task
-	err := json.Unmarshal([]byte(result.Val()), &task)
+	task, err := tq.decodeTask(ctx, []byte(raw), true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal task: %v", err)
+		tq.handleUndecodableTask(ctx, raw, err)
+		return nil, err
 	}
+	task.raw = raw
 
 	// อัพเดทสถานะเป็น processing
 	task.Status = TaskStatusProcessing
@@ -124,12 +198,12 @@ func (tq *TaskQueue) DequeueTask(ctx context.Context, timeout time.Duration) (*T
 	now := time.Now()
 	task.ProcessedAt = &now
 
-	err = tq.updateTaskStatus(ctx, &task)
+	err = tq.updateTaskStatus(ctx, task)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update task status: %v", err)
 	}
 
-	return &task, nil
+	return task, nil
 }
 
 // CompleteTask ทำเครื่องหมายว่า task เสร็จสิ้นแล้ว
@@ -138,28 +212,24 @@ func (tq *TaskQueue) CompleteTask(ctx context.Context, task *Task) error {
 	task.UpdatedAt = time.Now()
 
 	// ลบจาก processing list
-	taskJSON, err := json.Marshal(task)
-	if err != nil {
-		return fmt.Errorf("failed to marshal task: %v", err)
-	}
+	tq.removeFromProcessing(ctx, task)
 
-	err = tq.client.rdbc.LRem(ctx, TaskProcessingKey, 1, taskJSON).Err()
-	if err != nil {
-		log.Printf("Warning: failed to remove task from processing list: %v", err)
-	}
-
-	// อัพเดทสถานะ
-	err = tq.updateTaskStatus(ctx, task)
+	// อัพเดทสถานะแล้วคืน slot ของ tenant ครั้งเดียว แม้อัพเดทไม่สำเร็จ task ก็ออกจาก processing แล้ว
+	err := tq.updateTaskStatus(ctx, task)
+	tq.releaseTenantSlot(ctx, task)
 	if err != nil {
 		return fmt.Errorf("failed to update task status: %v", err)
 	}
 
 	// ลบ task detail หลังจากเสร็จสิ้น
-	err = tq.client.rdbc.Del(ctx, fmt.Sprintf("task:%s", task.ID)).Err()
+	err = tq.client.rdbc.Del(ctx, tq.taskKey(task.ID)).Err()
 	if err != nil {
 		log.Printf("Warning: failed to delete task details: %v", err)
 	}
 
+	tq.deletePayloadBlob(ctx, task)
+	tq.archiveTask(ctx, task)
+
 	return nil
 }
 
@@ -176,17 +246,18 @@ func (tq *TaskQueue) FailTask(ctx context.Context, task *Task, errorMsg string)
 		task.FailedAt = &now
 
 		// ย้ายไป failed queue
-		taskJSON, err := json.Marshal(task)
+		taskJSON, err := tq.encodeTask(task)
 		if err != nil {
-			return fmt.Errorf("failed to marshal task: %v", err)
+			return err
 		}
 
-		err = tq.client.rdbc.LPush(ctx, TaskFailedKey, taskJSON).Err()
+		err = tq.client.rdbc.LPush(ctx, tq.key(TaskFailedKey), taskJSON).Err()
 		if err != nil {
 			log.Printf("Warning: failed to add task to failed queue: %v", err)
 		}
 
 		log.Printf("Task %s failed permanently after %d retries: %s", task.ID, task.RetryCount, errorMsg)
+		tq.archiveTask(ctx, task)
 	} else {
 		// Retry task
 		task.Status = TaskStatusRetrying
@@ -198,15 +269,15 @@ func (tq *TaskQueue) FailTask(ctx context.Context, task *Task, errorMsg string)
 		}
 
 		// เพิ่มกลับเข้า queue หลังจาก delay
-		taskJSON, err := json.Marshal(task)
+		taskJSON, err := tq.encodeTask(task)
 		if err != nil {
-			return fmt.Errorf("failed to marshal task: %v", err)
+			return err
 		}
 
 		// ใช้ delayed queue pattern
 		go func() {
 			time.Sleep(delay)
-			err := tq.client.rdbc.LPush(context.Background(), TaskQueueKey, taskJSON).Err()
+			err := tq.pushPending(context.Background(), tq.client.rdbc, task, taskJSON)
 			if err != nil {
 				log.Printf("Failed to re-enqueue task %s: %v", task.ID, err)
 			} else {
@@ -216,19 +287,12 @@ func (tq *TaskQueue) FailTask(ctx context.Context, task *Task, errorMsg string)
 		}()
 	}
 
-	// ลบจาก processing list
-	taskJSON, err := json.Marshal(task)
-	if err != nil {
-		return fmt.Errorf("failed to marshal task: %v", err)
-	}
-
-	err = tq.client.rdbc.LRem(ctx, TaskProcessingKey, 1, taskJSON).Err()
-	if err != nil {
-		log.Printf("Warning: failed to remove task from processing list: %v", err)
-	}
+	// ลบจาก processing list และคืน slot ของ tenant เพื่อไม่ให้ tenant ที่ task ล้มบ่อยติด cap
+	tq.removeFromProcessing(ctx, task)
+	tq.releaseTenantSlot(ctx, task)
 
 	// อัพเดทสถานะ
-	err = tq.updateTaskStatus(ctx, task)
+	err := tq.updateTaskStatus(ctx, task)
 	if err != nil {
 		return fmt.Errorf("failed to update task status: %v", err)
 	}
@@ -236,9 +300,32 @@ func (tq *TaskQueue) FailTask(ctx context.Context, task *Task, errorMsg string)
 	return nil
 }
 
+// handleUndecodableTask เอา task ที่ decode ไม่ได้ออกจาก processing list เพื่อไม่ให้ค้าง
+// ถ้าอ่าน JSON ได้แต่โหลด payload ไม่ได้ (เช่น BlobStore ล่ม) จะ retry ผ่าน FailTask
+// ถ้า JSON เสียจะย้ายไป failed queue ทันที
+func (tq *TaskQueue) handleUndecodableTask(ctx context.Context, raw string, cause error) {
+	task, err := tq.decodeTask(ctx, []byte(raw), false)
+	if err == nil {
+		task.raw = raw
+		if err := tq.FailTask(ctx, task, cause.Error()); err != nil {
+			log.Printf("Warning: failed to fail undecodable task %s: %v", task.ID, err)
+		}
+		return
+	}
+
+	_, err = tq.client.rdbc.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+		pipe.LRem(ctx, tq.key(TaskProcessingKey), 1, raw)
+		pipe.LPush(ctx, tq.key(TaskFailedKey), raw)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: failed to move malformed task to failed queue: %v", err)
+	}
+}
+
 // GetTaskStatus ดูสถานะของ task
 func (tq *TaskQueue) GetTaskStatus(ctx context.Context, taskID string) (*Task, error) {
-	result := tq.client.rdbc.HGet(ctx, fmt.Sprintf("task:%s", taskID), taskID)
+	result := tq.client.rdbc.HGet(ctx, tq.taskKey(taskID), taskID)
 	if result.Err() != nil {
 		if result.Err() == rdb.Nil {
 			return nil, nil // ไม่พบ task
@@ -246,26 +333,19 @@ func (tq *TaskQueue) GetTaskStatus(ctx context.Context, taskID string) (*Task, e
 		return nil, fmt.Errorf("failed to get task status: %v", result.Err())
 	}
 
-	var task Task
-	err := json.Unmarshal([]byte(result.Val()), &task)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal task: %v", err)
-	}
-
-	return &task, nil
+	return tq.decodeTask(ctx, []byte(result.Val()), true)
 }
 
-// RecoverStuckTasks ดึง tasks ที่ค้างอยู่ใน processing กลับมา queue
+// RecoverStuckTasks ดึง tasks ที่ค้างอยู่ใน processing กลับมา queue และคืน slot ของ tenant ที่ค้าง
 func (tq *TaskQueue) RecoverStuckTasks(ctx context.Context, stuckTimeout time.Duration) error {
-	processingTasks := tq.client.rdbc.LRange(ctx, TaskProcessingKey, 0, -1)
+	processingTasks := tq.client.rdbc.LRange(ctx, tq.key(TaskProcessingKey), 0, -1)
 	if processingTasks.Err() != nil {
 		return fmt.Errorf("failed to get processing tasks: %v", processingTasks.Err())
 	}
 
 	recoveredCount := 0
 	for _, taskJSON := range processingTasks.Val() {
-		var task Task
-		err := json.Unmarshal([]byte(taskJSON), &task)
+		task, err := tq.decodeTask(ctx, []byte(taskJSON), false)
 		if err != nil {
 			log.Printf("Failed to unmarshal processing task: %v", err)
 			continue
@@ -274,7 +354,7 @@ func (tq *TaskQueue) RecoverStuckTasks(ctx context.Context, stuckTimeout time.Du
 		// ตรวจสอบว่า task ค้างนานเกินกำหนดหรือไม่
 		if task.ProcessedAt != nil && time.Since(*task.ProcessedAt) > stuckTimeout {
 			// ย้ายกลับเข้า queue
-			err = tq.client.rdbc.LRem(ctx, TaskProcessingKey, 1, taskJSON).Err()
+			err = tq.client.rdbc.LRem(ctx, tq.key(TaskProcessingKey), 1, taskJSON).Err()
 			if err != nil {
 				log.Printf("Failed to remove stuck task from processing: %v", err)
 				continue
@@ -284,19 +364,20 @@ func (tq *TaskQueue) RecoverStuckTasks(ctx context.Context, stuckTimeout time.Du
 			task.UpdatedAt = time.Now()
 			task.ProcessedAt = nil
 
-			newTaskJSON, err := json.Marshal(task)
+			newTaskJSON, err := tq.encodeTask(task)
 			if err != nil {
 				log.Printf("Failed to marshal recovered task: %v", err)
 				continue
 			}
 
-			err = tq.client.rdbc.LPush(ctx, TaskQueueKey, newTaskJSON).Err()
+			err = tq.pushPending(ctx, tq.client.rdbc, task, newTaskJSON)
 			if err != nil {
 				log.Printf("Failed to re-enqueue recovered task: %v", err)
 				continue
 			}
+			tq.releaseTenantSlot(ctx, task)
 
-			err = tq.updateTaskStatus(ctx, &task)
+			err = tq.updateTaskStatus(ctx, task)
 			if err != nil {
 				log.Printf("Failed to update recovered task status: %v", err)
 			}
@@ -310,6 +391,14 @@ func (tq *TaskQueue) RecoverStuckTasks(ctx context.Context, stuckTimeout time.Du
 		log.Printf("Recovered %d stuck tasks", recoveredCount)
 	}
 
+	// คืน slot ของ tenant ที่ค้างจาก worker ที่ล้มหรือคืน slot ไม่สำเร็จ
+	reaped, err := tq.reapTenantSlots(ctx, stuckTimeout)
+	if err != nil {
+		log.Printf("Failed to reap tenant slots: %v", err)
+	} else if reaped > 0 {
+		log.Printf("Reaped %d stale tenant slots", reaped)
+	}
+
 	return nil
 }
 
@@ -318,19 +407,19 @@ func (tq *TaskQueue) GetQueueStats(ctx context.Context) (map[string]int64, error
 	stats := make(map[string]int64)
 
 	// นับ pending tasks
-	pendingCount := tq.client.rdbc.LLen(ctx, TaskQueueKey)
+	pendingCount := tq.client.rdbc.LLen(ctx, tq.key(TaskQueueKey))
 	if pendingCount.Err() == nil {
 		stats["pending"] = pendingCount.Val()
 	}
 
 	// นับ processing tasks
-	processingCount := tq.client.rdbc.LLen(ctx, TaskProcessingKey)
+	processingCount := tq.client.rdbc.LLen(ctx, tq.key(TaskProcessingKey))
 	if processingCount.Err() == nil {
 		stats["processing"] = processingCount.Val()
 	}
 
 	// นับ failed tasks
-	failedCount := tq.client.rdbc.LLen(ctx, TaskFailedKey)
+	failedCount := tq.client.rdbc.LLen(ctx, tq.key(TaskFailedKey))
 	if failedCount.Err() == nil {
 		stats["failed"] = failedCount.Val()
 	}
@@ -338,17 +427,107 @@ func (tq *TaskQueue) GetQueueStats(ctx context.Context) (map[string]int64, error
 	return stats, nil
 }
 
+// key คืนชื่อ key ของ queue โดยใส่ hash tag เมื่อใช้ Redis Cluster
+// เพื่อให้คำสั่ง multi-key เช่น BRPOPLPUSH ทำงานได้
+func (tq *TaskQueue) key(name string) string {
+	if tq.client.cluster {
+		return HashTag(taskHashTag, name)
+	}
+	return name
+}
+
+// taskKey คืนชื่อ key ที่เก็บรายละเอียดของ task
+func (tq *TaskQueue) taskKey(taskID string) string {
+	return tq.key(fmt.Sprintf("task:%s", taskID))
+}
+
 // updateTaskStatus อัพเดทสถานะของ task ใน Redis
 func (tq *TaskQueue) updateTaskStatus(ctx context.Context, task *Task) error {
-	taskJSON, err := json.Marshal(task)
+	taskJSON, err := tq.encodeTask(task)
 	if err != nil {
-		return fmt.Errorf("failed to marshal task: %v", err)
+		return err
 	}
 
-	err = tq.client.rdbc.HSet(ctx, fmt.Sprintf("task:%s", task.ID), task.ID, taskJSON).Err()
+	err = tq.client.rdbc.HSet(ctx, tq.taskKey(task.ID), task.ID, taskJSON).Err()
 	if err != nil {
 		return fmt.Errorf("failed to update task status: %v", err)
 	}
 
 	return nil
 }
+
+// removeFromProcessing ลบ task ออกจาก processing list ด้วย JSON ตัวเดียวกับที่ถูก dequeue มา
+func (tq *TaskQueue) removeFromProcessing(ctx context.Context, task *Task) {
+	if task.raw == "" {
+		return
+	}
+
+	err := tq.client.rdbc.LRem(ctx, tq.key(TaskProcessingKey), 1, task.raw).Err()
+	if err != nil {
+		log.Printf("Warning: failed to remove task from processing list: %v", err)
+	}
+}
+
+// encodeTask แปลง task เป็น JSON โดยส่ง payload ผ่าน PayloadCodec ถ้ามี
+// task ที่มี PayloadRef จะไม่เก็บ payload ซ้ำใน Redis
+func (tq *TaskQueue) encodeTask(task *Task) ([]byte, error) {
+	stored := *task
+	if stored.PayloadRef != "" {
+		stored.Payload = nil
+		stored.EncodedPayload = nil
+	} else if tq.payloadCodec != nil && stored.Payload != nil {
+		payloadJSON, err := json.Marshal(stored.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal task payload: %v", err)
+		}
+		encoded, err := tq.payloadCodec.Encode(payloadJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode task payload: %v", err)
+		}
+		stored.Payload = nil
+		stored.EncodedPayload = encoded
+	}
+
+	taskJSON, err := json.Marshal(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task: %v", err)
+	}
+	return taskJSON, nil
+}
+
+// decodeTask แปลง JSON กลับเป็น task และถอด EncodedPayload กลับเป็น Payload
+// loadBlob กำหนดว่าจะดึง payload จาก BlobStore หรือไม่ เมื่อ task มี PayloadRef
+func (tq *TaskQueue) decodeTask(ctx context.Context, data []byte, loadBlob bool) (*Task, error) {
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task: %v", err)
+	}
+
+	if task.PayloadRef != "" {
+		if !loadBlob {
+			return &task, nil
+		}
+		payload, err := tq.loadPayload(ctx, task.PayloadRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load payload of task %s: %v", task.ID, err)
+		}
+		task.Payload = payload
+		return &task, nil
+	}
+
+	if len(task.EncodedPayload) > 0 {
+		if tq.payloadCodec == nil {
+			return nil, fmt.Errorf("task %s has encoded payload but no payload codec is configured", task.ID)
+		}
+		payloadJSON, err := tq.payloadCodec.Decode(task.EncodedPayload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode task payload: %v", err)
+		}
+		if err := json.Unmarshal(payloadJSON, &task.Payload); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal task payload: %v", err)
+		}
+		task.EncodedPayload = nil
+	}
+
+	return &task, nil
+}
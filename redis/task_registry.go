@@ -0,0 +1,243 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	TaskWorkersKey                 = "task_workers"
+	DefaultWorkerHeartbeatInterval = 10 * time.Second
+	DefaultWorkerTTL               = 30 * time.Second
+
+	// dequeue loop ถือว่าค้างถ้าไม่วนกลับมานานกว่า timeout ของ task บวกกับ dequeue timeout
+	dequeueStallTimeout  = DefaultTimeout + 2*dequeueTimeout
+	recoveryStallTimeout = 2*recoveryInterval + time.Minute
+)
+
+// WorkerInfo คือข้อมูลของ TaskWorker ที่ลงทะเบียนไว้ใน Redis
+type WorkerInfo struct {
+	ID             string     `json:"id"`
+	Hostname       string     `json:"hostname"`
+	PID            int        `json:"pid"`
+	StartedAt      time.Time  `json:"started_at"`
+	TaskTypes      []TaskType `json:"task_types"`
+	CurrentTasks   []string   `json:"current_tasks"`
+	LastHeartbeat  time.Time  `json:"last_heartbeat"`
+	LastDequeueAt  time.Time  `json:"last_dequeue_at"`
+	LastRecoveryAt time.Time  `json:"last_recovery_at"`
+}
+
+// workerLiveness เก็บเวลาที่แต่ละ loop ทำงานล่าสุด ใช้ตรวจว่า loop ค้างหรือไม่
+type workerLiveness struct {
+	startedAt    time.Time
+	lastDequeue  atomic.Int64
+	lastRecovery atomic.Int64
+}
+
+func (l *workerLiveness) touchDequeue() {
+	l.lastDequeue.Store(time.Now().UnixNano())
+}
+
+func (l *workerLiveness) touchRecovery() {
+	l.lastRecovery.Store(time.Now().UnixNano())
+}
+
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func (tq *TaskQueue) workerKey(workerID string) string {
+	return tq.key("task_worker:" + workerID)
+}
+
+// registerWorker บันทึกข้อมูล worker พร้อม TTL และเวลา heartbeat ใน index
+func (tq *TaskQueue) registerWorker(ctx context.Context, info *WorkerInfo) error {
+	infoJSON, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal worker info: %v", err)
+	}
+
+	_, err = tq.client.rdbc.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+		pipe.Set(ctx, tq.workerKey(info.ID), infoJSON, DefaultWorkerTTL)
+		pipe.ZAdd(ctx, tq.key(TaskWorkersKey), rdb.Z{Score: float64(info.LastHeartbeat.Unix()), Member: info.ID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register worker: %v", err)
+	}
+	return nil
+}
+
+// unregisterWorker ลบ worker ออกจาก registry เมื่อหยุดทำงานตามปกติ
+func (tq *TaskQueue) unregisterWorker(ctx context.Context, workerID string) error {
+	_, err := tq.client.rdbc.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+		pipe.Del(ctx, tq.workerKey(workerID))
+		pipe.ZRem(ctx, tq.key(TaskWorkersKey), workerID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unregister worker: %v", err)
+	}
+	return nil
+}
+
+// ListWorkers ดู worker ที่ยังส่ง heartbeat อยู่ worker ที่หมดอายุจะถูกลบออกจาก index
+func (tq *TaskQueue) ListWorkers(ctx context.Context) ([]*WorkerInfo, error) {
+	indexKey := tq.key(TaskWorkersKey)
+	cutoff := strconv.FormatInt(time.Now().Add(-DefaultWorkerTTL).Unix(), 10)
+
+	if err := tq.client.rdbc.ZRemRangeByScore(ctx, indexKey, "-inf", "("+cutoff).Err(); err != nil {
+		log.Printf("Warning: failed to remove expired workers: %v", err)
+	}
+
+	workerIDs, err := tq.client.rdbc.ZRange(ctx, indexKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workers: %v", err)
+	}
+	if len(workerIDs) == 0 {
+		return []*WorkerInfo{}, nil
+	}
+
+	keys := make([]string, len(workerIDs))
+	for i, id := range workerIDs {
+		keys[i] = tq.workerKey(id)
+	}
+	values, err := tq.client.rdbc.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workers: %v", err)
+	}
+
+	workers := make([]*WorkerInfo, 0, len(values))
+	for _, value := range values {
+		infoJSON, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var info WorkerInfo
+		if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+			log.Printf("Failed to unmarshal worker info: %v", err)
+			continue
+		}
+		workers = append(workers, &info)
+	}
+	return workers, nil
+}
+
+// Info คืนข้อมูลปัจจุบันของ worker ชุดเดียวกับที่ลงทะเบียนใน Redis
+func (tw *TaskWorker) Info() *WorkerInfo {
+	hostname, _ := os.Hostname()
+
+	tw.mu.RLock()
+	taskTypes := make([]TaskType, 0, len(tw.handlers))
+	for taskType := range tw.handlers {
+		taskTypes = append(taskTypes, taskType)
+	}
+	currentTasks := make([]string, 0, len(tw.currentTasks))
+	for taskID := range tw.currentTasks {
+		currentTasks = append(currentTasks, taskID)
+	}
+	startedAt := tw.liveness.startedAt
+	tw.mu.RUnlock()
+
+	sort.Slice(taskTypes, func(i, j int) bool { return taskTypes[i] < taskTypes[j] })
+	sort.Strings(currentTasks)
+
+	return &WorkerInfo{
+		ID:             tw.id,
+		Hostname:       hostname,
+		PID:            os.Getpid(),
+		StartedAt:      startedAt,
+		TaskTypes:      taskTypes,
+		CurrentTasks:   currentTasks,
+		LastHeartbeat:  time.Now(),
+		LastDequeueAt:  unixNanoTime(tw.liveness.lastDequeue.Load()),
+		LastRecoveryAt: unixNanoTime(tw.liveness.lastRecovery.Load()),
+	}
+}
+
+// Healthy คืน error ถ้า worker ไม่ได้ทำงาน หรือ dequeue loop หรือ recovery loop ค้าง
+func (tw *TaskWorker) Healthy() error {
+	tw.mu.RLock()
+	running := tw.running
+	tw.mu.RUnlock()
+	if !running {
+		return fmt.Errorf("task worker is not running")
+	}
+
+	if lastDequeue := unixNanoTime(tw.liveness.lastDequeue.Load()); time.Since(lastDequeue) > dequeueStallTimeout {
+		return fmt.Errorf("dequeue loop has stalled since %s", lastDequeue.Format(time.RFC3339))
+	}
+	if lastRecovery := unixNanoTime(tw.liveness.lastRecovery.Load()); time.Since(lastRecovery) > recoveryStallTimeout {
+		return fmt.Errorf("recovery loop has stalled since %s", lastRecovery.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// HealthHandler คืน echo handler สำหรับ /healthz/worker
+// ตอบ 200 พร้อมข้อมูล worker เมื่อปกติ และ 503 เมื่อ loop ใด loop หนึ่งค้าง
+//
+//	e.GET("/healthz/worker", worker.HealthHandler())
+func (tw *TaskWorker) HealthHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		info := tw.Info()
+		if err := tw.Healthy(); err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+				"status": "unhealthy",
+				"error":  err.Error(),
+				"worker": info,
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"status": "ok",
+			"worker": info,
+		})
+	}
+}
+
+// heartbeatLoop ลงทะเบียน worker ซ้ำเป็นระยะเพื่อต่ออายุ TTL
+func (tw *TaskWorker) heartbeatLoop(ctx context.Context) {
+	defer tw.wg.Done()
+
+	ticker := time.NewTicker(DefaultWorkerHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tw.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := tw.taskQueue.registerWorker(ctx, tw.Info()); err != nil {
+				log.Printf("Failed to send worker heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+func (tw *TaskWorker) trackTask(taskID string) {
+	tw.mu.Lock()
+	tw.currentTasks[taskID] = struct{}{}
+	tw.mu.Unlock()
+}
+
+func (tw *TaskWorker) untrackTask(taskID string) {
+	tw.mu.Lock()
+	delete(tw.currentTasks, taskID)
+	tw.mu.Unlock()
+}
@@ -0,0 +1,263 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	TaskTenantQueuePrefix     = "task_tenant_queue:"
+	TaskTenantRingKey         = "task_tenant_ring"
+	TaskTenantActiveKey       = "task_tenant_active"
+	TaskTenantInflightKey     = "task_tenant_inflight_entries"
+	TaskTenantInflightPrefix  = "task_tenant_inflight:"
+	DefaultTenantPollInterval = 100 * time.Millisecond
+)
+
+type TenantStats struct {
+	Pending  int64 `json:"pending"`
+	InFlight int64 `json:"in_flight"`
+}
+
+// tenantEnqueueScript เพิ่ม task เข้า sub-queue ของ tenant และใส่ tenant เข้า ring ถ้ายังไม่มี
+var tenantEnqueueScript = rdb.NewScript(`
+redis.call("LPUSH", KEYS[1], ARGV[1])
+if redis.call("SADD", KEYS[2], ARGV[2]) == 1 then
+	redis.call("RPUSH", KEYS[3], ARGV[2])
+end
+return 1
+`)
+
+// tenantDequeueScript หมุน ring ทีละ tenant (round-robin) แล้วดึง task จาก tenant แรก
+// ที่มี task ค้างและยังไม่เกิน concurrency cap ส่วน tenant ที่ queue ว่างจะถูกเอาออกจาก ring
+// slot ของ tenant นับเป็นรายการ task id พร้อมเวลาที่ dequeue เพื่อให้คืน slot ซ้ำได้และ reap slot ที่ค้างได้
+// sub-queue ทุกตัวใช้ prefix เดียวกับ KEYS จึงอยู่ slot เดียวกันเมื่อใช้ Redis Cluster
+var tenantDequeueScript = rdb.NewScript(`
+local ring = KEYS[1]
+local active = KEYS[2]
+local inflight = KEYS[3]
+local processing = KEYS[4]
+local prefix = ARGV[1]
+local limit = tonumber(ARGV[2])
+local inflightPrefix = ARGV[3]
+local now = tonumber(ARGV[4])
+
+local n = redis.call("LLEN", ring)
+for i = 1, n do
+	local entry = redis.call("RPOPLPUSH", ring, ring)
+	if not entry then
+		return false
+	end
+	local queue = prefix .. entry
+	if redis.call("LLEN", queue) == 0 then
+		redis.call("LREM", ring, 0, entry)
+		redis.call("SREM", active, entry)
+	else
+		local slots = inflightPrefix .. entry
+		if limit <= 0 or redis.call("ZCARD", slots) < limit then
+			local task = redis.call("RPOPLPUSH", queue, processing)
+			local ok, decoded = pcall(cjson.decode, task)
+			if ok and type(decoded) == "table" and type(decoded.id) == "string" then
+				redis.call("ZADD", slots, now, decoded.id)
+				redis.call("SADD", inflight, entry)
+			end
+			return task
+		end
+	end
+end
+return false
+`)
+
+// tenantReleaseScript คืน slot ของ task เรียกซ้ำได้โดยไม่ทำให้ตัวนับผิด
+var tenantReleaseScript = rdb.NewScript(`
+redis.call("ZREM", KEYS[1], ARGV[2])
+if redis.call("ZCARD", KEYS[1]) == 0 then
+	redis.call("SREM", KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+// tenantReapScript คืน slot ที่ถือไว้นานกว่า cutoff เช่นเมื่อ worker ล้มหรือคืน slot ไม่สำเร็จ
+var tenantReapScript = rdb.NewScript(`
+local reaped = 0
+for _, entry in ipairs(redis.call("SMEMBERS", KEYS[1])) do
+	local slots = ARGV[1] .. entry
+	reaped = reaped + redis.call("ZREMRANGEBYSCORE", slots, "-inf", "(" .. ARGV[2])
+	if redis.call("ZCARD", slots) == 0 then
+		redis.call("SREM", KEYS[1], entry)
+	end
+end
+return reaped
+`)
+
+// WithTenantFairness เปิด fair scheduling ข้าม tenant ภายใน task type เดียวกัน
+// task ที่มี TenantID จะเข้า sub-queue ของ tenant และถูก dequeue แบบ round-robin
+// maxConcurrentPerTenant จำกัดจำนวน task ของ tenant หนึ่งต่อ type ที่ประมวลผลพร้อมกัน (0 คือไม่จำกัด)
+func WithTenantFairness(maxConcurrentPerTenant int) TaskQueueOption {
+	return func(tq *TaskQueue) {
+		tq.tenantFair = true
+		tq.tenantMaxConcurrent = maxConcurrentPerTenant
+	}
+}
+
+// EnqueueTenantTask เพิ่ม task ของ tenant เข้า queue
+func (tq *TaskQueue) EnqueueTenantTask(ctx context.Context, tenantID string, taskType TaskType, payload map[string]interface{}) (*Task, error) {
+	return tq.enqueueTask(ctx, tenantID, taskType, payload)
+}
+
+// tenantEntry คือชื่อของ tenant ใน ring ในรูปแบบ type:tenant
+func tenantEntry(taskType TaskType, tenantID string) string {
+	return taskType.String() + ":" + tenantID
+}
+
+func (tq *TaskQueue) isTenantTask(task *Task) bool {
+	return tq.tenantFair && task.TenantID != ""
+}
+
+// pushPending ใส่ task กลับเข้า queue ที่ถูกต้อง (sub-queue ของ tenant หรือ queue หลัก)
+// c เป็นได้ทั้ง client และ pipeline
+func (tq *TaskQueue) pushPending(ctx context.Context, c rdb.Cmdable, task *Task, taskJSON []byte) error {
+	if !tq.isTenantTask(task) {
+		return c.LPush(ctx, tq.key(TaskQueueKey), taskJSON).Err()
+	}
+
+	entry := tenantEntry(task.Type, task.TenantID)
+	keys := []string{tq.key(TaskTenantQueuePrefix + entry), tq.key(TaskTenantActiveKey), tq.key(TaskTenantRingKey)}
+	err := tenantEnqueueScript.Eval(ctx, c, keys, taskJSON, entry).Err()
+	if err == rdb.Nil {
+		return nil
+	}
+	return err
+}
+
+// releaseTenantSlot คืน concurrency slot ของ tenant เมื่อ task ออกจาก processing
+// slot ผูกกับ task id จึงเรียกซ้ำสำหรับ task เดียวกันได้
+func (tq *TaskQueue) releaseTenantSlot(ctx context.Context, task *Task) {
+	if !tq.isTenantTask(task) {
+		return
+	}
+
+	entry := tenantEntry(task.Type, task.TenantID)
+	keys := []string{tq.key(TaskTenantInflightPrefix + entry), tq.key(TaskTenantInflightKey)}
+	if err := tenantReleaseScript.Run(ctx, tq.client.rdbc, keys, entry, task.ID).Err(); err != nil {
+		log.Printf("Warning: failed to release tenant slot %s: %v", entry, err)
+	}
+}
+
+// reapTenantSlots คืน slot ที่ถือไว้นานกว่า timeout ซึ่ง task ของ slot นั้นถือว่าค้างแล้ว
+func (tq *TaskQueue) reapTenantSlots(ctx context.Context, timeout time.Duration) (int64, error) {
+	if !tq.tenantFair {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-timeout).UnixMilli()
+	return tenantReapScript.Run(ctx, tq.client.rdbc, []string{tq.key(TaskTenantInflightKey)},
+		tq.key(TaskTenantInflightPrefix), cutoff).Int64()
+}
+
+// dequeueFair ดึง task สลับกันระหว่าง sub-queue ของ tenant กับ queue หลัก
+// เพื่อไม่ให้ task ที่ไม่มี tenant รอจนกว่า tenant ทุกรายจะว่าง
+// ทำงานแบบ polling จนกว่าจะได้ task หรือครบ timeout คืนค่าว่างถ้าไม่มี task
+func (tq *TaskQueue) dequeueFair(ctx context.Context, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	keys := []string{
+		tq.key(TaskTenantRingKey),
+		tq.key(TaskTenantActiveKey),
+		tq.key(TaskTenantInflightKey),
+		tq.key(TaskProcessingKey),
+	}
+
+	dequeueTenant := func() (string, error) {
+		return tenantDequeueScript.Run(ctx, tq.client.rdbc, keys, tq.key(TaskTenantQueuePrefix), tq.tenantMaxConcurrent,
+			tq.key(TaskTenantInflightPrefix), time.Now().UnixMilli()).Text()
+	}
+	dequeueMain := func() (string, error) {
+		return tq.client.rdbc.RPopLPush(ctx, tq.key(TaskQueueKey), tq.key(TaskProcessingKey)).Result()
+	}
+
+	for {
+		sources := []func() (string, error){dequeueTenant, dequeueMain}
+		if tq.fairTurn.Add(1)%2 == 0 {
+			sources[0], sources[1] = sources[1], sources[0]
+		}
+
+		for _, dequeue := range sources {
+			taskJSON, err := dequeue()
+			if err != nil && err != rdb.Nil {
+				return "", err
+			}
+			if taskJSON != "" {
+				return taskJSON, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return "", nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(DefaultTenantPollInterval):
+		}
+	}
+}
+
+// GetTenantStats ดูจำนวน task ที่รออยู่และกำลังประมวลผลแยกตาม task type และ tenant
+func (tq *TaskQueue) GetTenantStats(ctx context.Context) (map[TaskType]map[string]TenantStats, error) {
+	stats := make(map[TaskType]map[string]TenantStats)
+	if !tq.tenantFair {
+		return stats, nil
+	}
+
+	entries, err := tq.client.rdbc.SMembers(ctx, tq.key(TaskTenantActiveKey)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active tenants: %v", err)
+	}
+	inflightEntries, err := tq.client.rdbc.SMembers(ctx, tq.key(TaskTenantInflightKey)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant in-flight counts: %v", err)
+	}
+
+	pending := make(map[string]*rdb.IntCmd, len(entries))
+	inflight := make(map[string]*rdb.IntCmd, len(inflightEntries))
+	_, err = tq.client.rdbc.Pipelined(ctx, func(pipe rdb.Pipeliner) error {
+		for _, entry := range entries {
+			pending[entry] = pipe.LLen(ctx, tq.key(TaskTenantQueuePrefix+entry))
+		}
+		for _, entry := range inflightEntries {
+			inflight[entry] = pipe.ZCard(ctx, tq.key(TaskTenantInflightPrefix+entry))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant queue length: %v", err)
+	}
+
+	set := func(entry string, update func(s *TenantStats)) {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return
+		}
+		taskType := TaskType(parts[0])
+		if stats[taskType] == nil {
+			stats[taskType] = make(map[string]TenantStats)
+		}
+		s := stats[taskType][parts[1]]
+		update(&s)
+		stats[taskType][parts[1]] = s
+	}
+
+	for entry, cmd := range pending {
+		set(entry, func(s *TenantStats) { s.Pending = cmd.Val() })
+	}
+	for entry, cmd := range inflight {
+		set(entry, func(s *TenantStats) { s.InFlight = cmd.Val() })
+	}
+
+	return stats, nil
+}
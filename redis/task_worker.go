@@ -4,13 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"sync"
 	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	dequeueTimeout   = 5 * time.Second
+	recoveryInterval = 2 * time.Minute
 )
 
 type TaskHandler func(ctx context.Context, task *Task) error
 
 type TaskWorker struct {
+	id          string
 	taskQueue   *TaskQueue
 	handlers    map[TaskType]TaskHandler
 	workerCount int
@@ -18,19 +27,36 @@ type TaskWorker struct {
 	stopChan    chan struct{}
 	wg          sync.WaitGroup
 	mu          sync.RWMutex
+
+	pausedTypes map[TaskType]bool
+	queuePaused bool
+
+	currentTasks map[string]struct{}
+	liveness     workerLiveness
 }
 
 func NewTaskWorker(taskQueue *TaskQueue, workerCount int) *TaskWorker {
+	hostname, _ := os.Hostname()
+	instanceID, _ := uuid.NewV4()
 	worker := &TaskWorker{
+		id:          fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), instanceID.String()[:8]),
 		taskQueue:   taskQueue,
 		handlers:    make(map[TaskType]TaskHandler),
 		workerCount: workerCount,
 		stopChan:    make(chan struct{}),
+		pausedTypes: make(map[TaskType]bool),
+
+		currentTasks: make(map[string]struct{}),
 	}
 
 	return worker
 }
 
+// ID คืน id ของ worker ในรูปแบบ host:pid:random
+func (tw *TaskWorker) ID() string {
+	return tw.id
+}
+
 // RegisterHandler ลงทะเบียน handler สำหรับ task type ใหม่
 func (tw *TaskWorker) RegisterHandler(taskType TaskType, handler TaskHandler) {
 	tw.mu.Lock()
@@ -46,12 +72,27 @@ func (tw *TaskWorker) Start(ctx context.Context) {
 		return
 	}
 	tw.running = true
+	tw.liveness.startedAt = time.Now()
 	tw.mu.Unlock()
 
+	// ลงทะเบียน worker แล้วส่ง heartbeat เป็นระยะ
+	tw.liveness.touchDequeue()
+	tw.liveness.touchRecovery()
+	if err := tw.taskQueue.registerWorker(ctx, tw.Info()); err != nil {
+		log.Printf("Failed to register worker: %v", err)
+	}
+	tw.wg.Add(1)
+	go tw.heartbeatLoop(ctx)
+
 	// เริ่ม recovery goroutine
 	tw.wg.Add(1)
 	go tw.recoveryLoop(ctx)
 
+	// โหลดสถานะ pause ก่อนเริ่ม dequeue แล้วคอย refresh เป็นระยะ
+	tw.refreshPauseState(ctx)
+	tw.wg.Add(1)
+	go tw.pauseRefreshLoop(ctx)
+
 	// เริ่ม worker goroutines
 	for i := 0; i < tw.workerCount; i++ {
 		tw.wg.Add(1)
@@ -74,6 +115,10 @@ func (tw *TaskWorker) Stop() {
 	close(tw.stopChan)
 	tw.wg.Wait()
 
+	if err := tw.taskQueue.unregisterWorker(context.Background(), tw.id); err != nil {
+		log.Printf("Failed to unregister worker: %v", err)
+	}
+
 	log.Println("Stopping Task worker successfully!!")
 }
 
@@ -90,11 +135,22 @@ func (tw *TaskWorker) worker(ctx context.Context, workerID int) {
 			log.Printf("Worker %d stopping due to context cancellation", workerID)
 			return
 		default:
+			tw.liveness.touchDequeue()
+
+			if tw.isQueuePaused() {
+				if !tw.wait(ctx, DefaultPauseRefreshInterval) {
+					return
+				}
+				continue
+			}
+
 			// Dequeue task with timeout
-			task, err := tw.taskQueue.DequeueTask(ctx, 5*time.Second)
+			task, err := tw.taskQueue.DequeueTask(ctx, dequeueTimeout)
 			if err != nil {
 				log.Printf("Worker %d failed to dequeue task: %v", workerID, err)
-				time.Sleep(1 * time.Second)
+				if !tw.wait(ctx, 1*time.Second) {
+					return
+				}
 				continue
 			}
 
@@ -103,10 +159,28 @@ func (tw *TaskWorker) worker(ctx context.Context, workerID int) {
 				continue
 			}
 
+			if tw.isTaskTypePaused(task.Type) {
+				// พัก task ไว้ใน list ของ type นั้นจนกว่าจะ resume ถ้า type ถูก resume ไปแล้วก็ประมวลผลต่อเลย
+				parked, err := tw.taskQueue.ParkTask(ctx, task)
+				if err != nil {
+					log.Printf("Worker %d failed to park paused task %s: %v", workerID, task.ID, err)
+					if err := tw.taskQueue.RequeueTask(ctx, task); err != nil {
+						log.Printf("Worker %d failed to requeue paused task %s: %v", workerID, task.ID, err)
+					}
+					continue
+				}
+				if parked {
+					continue
+				}
+			}
+
+			task.WorkerID = tw.id
 			log.Printf("Worker %d processing task %s (type: %s)", workerID, task.ID, task.Type)
 
 			// Process task
+			tw.trackTask(task.ID)
 			err = tw.processTask(ctx, task)
+			tw.untrackTask(task.ID)
 			if err != nil {
 				log.Printf("Worker %d failed to process task %s: %v", workerID, task.ID, err)
 
@@ -128,6 +202,18 @@ func (tw *TaskWorker) worker(ctx context.Context, workerID int) {
 	}
 }
 
+// wait รอ d หรือจนกว่า worker จะถูกหยุด คืนค่า false ถ้า worker ถูกหยุดระหว่างรอ
+func (tw *TaskWorker) wait(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-tw.stopChan:
+		return false
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
 // processTask ประมวลผล task ตาม type
 func (tw *TaskWorker) processTask(ctx context.Context, task *Task) error {
 	tw.mu.RLock()
@@ -149,7 +235,7 @@ func (tw *TaskWorker) processTask(ctx context.Context, task *Task) error {
 func (tw *TaskWorker) recoveryLoop(ctx context.Context) {
 	defer tw.wg.Done()
 
-	ticker := time.NewTicker(2 * time.Minute) // Check every 2 minutes
+	ticker := time.NewTicker(recoveryInterval) // Check every 2 minutes
 	defer ticker.Stop()
 
 	for {
@@ -159,6 +245,8 @@ func (tw *TaskWorker) recoveryLoop(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			tw.liveness.touchRecovery()
+
 			// Recover tasks that have been stuck for more than 5 minutes
 			err := tw.taskQueue.RecoverStuckTasks(ctx, 5*time.Minute)
 			if err != nil {
@@ -168,6 +256,78 @@ func (tw *TaskWorker) recoveryLoop(ctx context.Context) {
 	}
 }
 
+// pauseRefreshLoop อ่านสถานะ pause จาก Redis เป็นระยะ
+func (tw *TaskWorker) pauseRefreshLoop(ctx context.Context) {
+	defer tw.wg.Done()
+
+	ticker := time.NewTicker(DefaultPauseRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tw.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			tw.refreshPauseState(ctx)
+		}
+	}
+}
+
+func (tw *TaskWorker) refreshPauseState(ctx context.Context) {
+	taskTypes, err := tw.taskQueue.GetPausedTaskTypes(ctx)
+	if err != nil {
+		log.Printf("Failed to refresh paused task types: %v", err)
+		return
+	}
+	queuePaused, err := tw.taskQueue.IsQueuePaused(ctx)
+	if err != nil {
+		log.Printf("Failed to refresh queue pause state: %v", err)
+		return
+	}
+
+	pausedTypes := make(map[TaskType]bool, len(taskTypes))
+	for _, taskType := range taskTypes {
+		pausedTypes[taskType] = true
+	}
+
+	tw.mu.Lock()
+	tw.pausedTypes = pausedTypes
+	tw.queuePaused = queuePaused
+	tw.mu.Unlock()
+}
+
+func (tw *TaskWorker) isTaskTypePaused(taskType TaskType) bool {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+	return tw.pausedTypes[taskType]
+}
+
+func (tw *TaskWorker) isQueuePaused() bool {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+	return tw.queuePaused
+}
+
+// PauseTaskType หยุดประมวลผล task type ในทุก worker replica
+func (tw *TaskWorker) PauseTaskType(ctx context.Context, taskType TaskType) error {
+	if err := tw.taskQueue.PauseTaskType(ctx, taskType); err != nil {
+		return err
+	}
+	tw.refreshPauseState(ctx)
+	return nil
+}
+
+// ResumeTaskType กลับมาประมวลผล task type ในทุก worker replica
+func (tw *TaskWorker) ResumeTaskType(ctx context.Context, taskType TaskType) error {
+	if err := tw.taskQueue.ResumeTaskType(ctx, taskType); err != nil {
+		return err
+	}
+	tw.refreshPauseState(ctx)
+	return nil
+}
+
 // GetStats ดูสถิติของ worker
 func (tw *TaskWorker) GetStats(ctx context.Context) (map[string]interface{}, error) {
 	queueStats, err := tw.taskQueue.GetQueueStats(ctx)
@@ -175,6 +335,24 @@ func (tw *TaskWorker) GetStats(ctx context.Context) (map[string]interface{}, err
 		return nil, err
 	}
 
+	pausedTypes, err := tw.taskQueue.GetPausedTaskTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queuePaused, err := tw.taskQueue.IsQueuePaused(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parkedTasks, err := tw.taskQueue.GetParkedTaskCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantStats, err := tw.taskQueue.GetTenantStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	tw.mu.RLock()
 	running := tw.running
 	workerCount := tw.workerCount
@@ -182,10 +360,14 @@ func (tw *TaskWorker) GetStats(ctx context.Context) (map[string]interface{}, err
 	tw.mu.RUnlock()
 
 	stats := map[string]interface{}{
-		"worker_count":  workerCount,
-		"handler_count": handlerCount,
-		"running":       running,
-		"queue_stats":   queueStats,
+		"worker_count":      workerCount,
+		"handler_count":     handlerCount,
+		"running":           running,
+		"queue_stats":       queueStats,
+		"queue_paused":      queuePaused,
+		"paused_task_types": pausedTypes,
+		"parked_tasks":      parkedTasks,
+		"tenant_stats":      tenantStats,
 	}
 
 	return stats, nil
@@ -0,0 +1,353 @@
+package redis
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/uuid"
+	rdb "github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultL1MaxEntries      = 10000
+	DefaultL1TTL             = time.Minute
+	DefaultInvalidationTopic = "cache.invalidate"
+	l1EntryOverhead          = 64
+)
+
+type TieredCacheConfig struct {
+	// MaxEntries จำนวน entry สูงสุดของ L1
+	MaxEntries int
+	// MaxBytes ขนาดรวมสูงสุดของ L1 (key + value) ถ้าเป็น 0 จะไม่จำกัด
+	MaxBytes int64
+	// L1TTL อายุสูงสุดของ entry ใน L1 ไม่ว่า TTL ใน Redis จะยาวแค่ไหน
+	L1TTL time.Duration
+	// InvalidationTopic คือ topic ที่ใช้แจ้ง replica อื่นให้ลบ L1
+	InvalidationTopic string
+	// Codec ที่ใช้กับ GetTiered/SetTiered ค่าเริ่มต้น JSONCodec
+	Codec Codec
+}
+
+type TieredCacheStats struct {
+	L1Hits    uint64 `json:"l1_hits"`
+	L1Misses  uint64 `json:"l1_misses"`
+	L2Hits    uint64 `json:"l2_hits"`
+	L2Misses  uint64 `json:"l2_misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+}
+
+type l1Entry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+func (e *l1Entry) size() int64 {
+	return int64(len(e.key)+len(e.value)) + l1EntryOverhead
+}
+
+// lruCache คือ LRU ที่จำกัดทั้งจำนวน entry และขนาดเป็น byte
+type lruCache struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	order      *list.List
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	evictions  uint64
+}
+
+func newLRUCache(maxEntries int, maxBytes int64) *lruCache {
+	return &lruCache{
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}
+}
+
+func (l *lruCache) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, exists := l.items[key]
+	if !exists {
+		return nil, false
+	}
+	entry := elem.Value.(*l1Entry)
+	if time.Now().After(entry.expireAt) {
+		l.removeElement(elem)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	// คืนสำเนาเพื่อไม่ให้ผู้เรียกแก้ค่าใน L1 ได้
+	return bytes.Clone(entry.value), true
+}
+
+func (l *lruCache) set(key string, value []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := &l1Entry{key: key, value: bytes.Clone(value), expireAt: time.Now().Add(ttl)}
+	if l.maxBytes > 0 && entry.size() > l.maxBytes {
+		// ใหญ่เกินกว่าจะเก็บใน L1 ได้
+		if elem, exists := l.items[key]; exists {
+			l.removeElement(elem)
+		}
+		return
+	}
+
+	if elem, exists := l.items[key]; exists {
+		l.bytes += entry.size() - elem.Value.(*l1Entry).size()
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+	} else {
+		l.items[key] = l.order.PushFront(entry)
+		l.bytes += entry.size()
+	}
+
+	for l.order.Len() > l.maxEntries || (l.maxBytes > 0 && l.bytes > l.maxBytes) {
+		l.removeElement(l.order.Back())
+		l.evictions++
+	}
+}
+
+func (l *lruCache) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, exists := l.items[key]; exists {
+		l.removeElement(elem)
+	}
+}
+
+func (l *lruCache) purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.items = make(map[string]*list.Element)
+	l.order.Init()
+	l.bytes = 0
+}
+
+func (l *lruCache) removeElement(elem *list.Element) {
+	entry := l.order.Remove(elem).(*l1Entry)
+	delete(l.items, entry.key)
+	l.bytes -= entry.size()
+}
+
+func (l *lruCache) stats() (int, int64, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len(), l.bytes, l.evictions
+}
+
+type cacheInvalidation struct {
+	Instance string   `json:"instance"`
+	Keys     []string `json:"keys"`
+	All      bool     `json:"all,omitempty"`
+}
+
+// TieredCache คือ cache สองชั้น: L1 เป็น LRU ในหน่วยความจำของ process, L2 คือ Redis
+// การเขียนหรือลบจะ publish invalidation ให้ทุก replica ลบ L1 ของ key นั้น
+type TieredCache struct {
+	client       *Client
+	config       TieredCacheConfig
+	l1           *lruCache
+	instanceID   string
+	subscription *Subscription
+
+	l1Hits   atomic.Uint64
+	l1Misses atomic.Uint64
+	l2Hits   atomic.Uint64
+	l2Misses atomic.Uint64
+}
+
+// NewTieredCache สร้าง cache และเริ่ม subscribe invalidation topic ต้องเรียก Close เมื่อเลิกใช้
+func NewTieredCache(ctx context.Context, client *Client, config TieredCacheConfig) (*TieredCache, error) {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultL1MaxEntries
+	}
+	if config.L1TTL <= 0 {
+		config.L1TTL = DefaultL1TTL
+	}
+	if config.InvalidationTopic == "" {
+		config.InvalidationTopic = DefaultInvalidationTopic
+	}
+	if config.Codec == nil {
+		config.Codec = JSONCodec
+	}
+
+	instanceID, _ := uuid.NewV4()
+	tc := &TieredCache{
+		client:     client,
+		config:     config,
+		l1:         newLRUCache(config.MaxEntries, config.MaxBytes),
+		instanceID: instanceID.String(),
+	}
+
+	// invalidation ที่พลาดไประหว่าง reconnect ทำให้ L1 ค้างได้ จึงล้าง L1 ทุกครั้งที่ subscribe ใหม่
+	subscription, err := client.Subscribe(ctx, config.InvalidationTopic, tc.handleInvalidation,
+		WithHandlerConcurrency(1), WithOnResubscribe(tc.l1.purge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe cache invalidation: %v", err)
+	}
+	tc.subscription = subscription
+
+	return tc, nil
+}
+
+func (tc *TieredCache) handleInvalidation(ctx context.Context, event *Event) error {
+	invalidation, err := DecodeEvent[cacheInvalidation](event)
+	if err != nil {
+		return err
+	}
+	if invalidation.Instance == tc.instanceID {
+		return nil
+	}
+
+	if invalidation.All {
+		tc.l1.purge()
+		return nil
+	}
+	for _, key := range invalidation.Keys {
+		tc.l1.delete(key)
+	}
+	return nil
+}
+
+func (tc *TieredCache) publishInvalidation(ctx context.Context, invalidation cacheInvalidation) error {
+	invalidation.Instance = tc.instanceID
+	if _, err := tc.client.Publish(ctx, tc.config.InvalidationTopic, invalidation); err != nil {
+		return fmt.Errorf("failed to publish cache invalidation: %v", err)
+	}
+	return nil
+}
+
+// Get อ่านจาก L1 ก่อน ถ้าไม่มีจึงอ่าน Redis แล้วเติม L1 โดยอายุใน L1 ไม่เกินอายุที่เหลือของ key ใน Redis
+func (tc *TieredCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if value, found := tc.l1.get(key); found {
+		tc.l1Hits.Add(1)
+		return value, true, nil
+	}
+	tc.l1Misses.Add(1)
+
+	// อ่าน PTTL พร้อมกันใน MULTI เพื่อไม่ให้ L1 เก็บค่าไว้นานกว่าที่ key ใน Redis จะหมดอายุ
+	var getCmd *rdb.StringCmd
+	var ttlCmd *rdb.DurationCmd
+	_, err := tc.client.rdbc.TxPipelined(ctx, func(pipe rdb.Pipeliner) error {
+		getCmd = pipe.Get(ctx, key)
+		ttlCmd = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil && err != rdb.Nil {
+		return nil, false, fmt.Errorf("failed to get key %s: %v", key, err)
+	}
+	value, err := getCmd.Bytes()
+	if err != nil {
+		if err == rdb.Nil {
+			tc.l2Misses.Add(1)
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get key %s: %v", key, err)
+	}
+	tc.l2Hits.Add(1)
+
+	// PTTL ติดลบคือ key ไม่มีวันหมดอายุ
+	l1TTL := tc.config.L1TTL
+	if ttl := ttlCmd.Val(); ttl > 0 && ttl < l1TTL {
+		l1TTL = ttl
+	}
+	tc.l1.set(key, value, l1TTL)
+	return value, true, nil
+}
+
+// Set เขียนลง Redis และ L1 แล้วแจ้ง replica อื่นให้ลบ L1 ของ key นี้
+func (tc *TieredCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := tc.client.rdbc.Set(ctx, key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set key %s: %v", key, err)
+	}
+
+	l1TTL := tc.config.L1TTL
+	if ttl > 0 && ttl < l1TTL {
+		l1TTL = ttl
+	}
+	tc.l1.set(key, value, l1TTL)
+
+	return tc.publishInvalidation(ctx, cacheInvalidation{Keys: []string{key}})
+}
+
+// Delete ลบ key ออกจากทั้งสองชั้นของทุก replica
+func (tc *TieredCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	for _, key := range keys {
+		tc.l1.delete(key)
+	}
+	// ลบทีละ key เพราะ key อาจอยู่คนละ slot ใน Redis Cluster
+	_, err := tc.client.rdbc.Pipelined(ctx, func(pipe rdb.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete keys: %v", err)
+	}
+
+	return tc.publishInvalidation(ctx, cacheInvalidation{Keys: keys})
+}
+
+// PurgeL1 ล้าง L1 ของทุก replica โดยไม่แตะข้อมูลใน Redis
+func (tc *TieredCache) PurgeL1(ctx context.Context) error {
+	tc.l1.purge()
+	return tc.publishInvalidation(ctx, cacheInvalidation{All: true})
+}
+
+func (tc *TieredCache) Stats() TieredCacheStats {
+	entries, bytes, evictions := tc.l1.stats()
+	return TieredCacheStats{
+		L1Hits:    tc.l1Hits.Load(),
+		L1Misses:  tc.l1Misses.Load(),
+		L2Hits:    tc.l2Hits.Load(),
+		L2Misses:  tc.l2Misses.Load(),
+		Evictions: evictions,
+		Entries:   entries,
+		Bytes:     bytes,
+	}
+}
+
+// Close หยุดฟัง invalidation
+func (tc *TieredCache) Close() error {
+	return tc.subscription.Close()
+}
+
+// GetTiered อ่านค่าจาก TieredCache แล้ว decode ด้วย codec ของ cache
+func GetTiered[T any](ctx context.Context, tc *TieredCache, key string) (value T, found bool, err error) {
+	data, found, err := tc.Get(ctx, key)
+	if err != nil || !found {
+		return value, found, err
+	}
+	if err := tc.config.Codec.Unmarshal(data, &value); err != nil {
+		return value, false, fmt.Errorf("failed to unmarshal key %s: %v", key, err)
+	}
+	return value, true, nil
+}
+
+// SetTiered encode value ด้วย codec ของ cache แล้วเขียนลง TieredCache
+func SetTiered[T any](ctx context.Context, tc *TieredCache, key string, value T, ttl time.Duration) error {
+	data, err := tc.config.Codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key %s: %v", key, err)
+	}
+	return tc.Set(ctx, key, data, ttl)
+}